@@ -0,0 +1,222 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// 认证类型
+const (
+	AuthTypeBearer = "bearer"
+	AuthTypeBasic  = "basic"
+	AuthTypeOAuth2 = "oauth2" // oauth2 client credentials
+)
+
+// 认证器, 为请求设置认证信息
+type authenticator interface {
+	// 为请求设置认证信息
+	authorize(ctx context.Context, r *Request) error
+	// 收到401响应时调用, 返回true表示已使认证信息失效, 可以重新认证后重试
+	invalidate() bool
+}
+
+func newAuthenticator(conf *AuthConfig, conn *clientConn) authenticator {
+	switch conf.Type {
+	case AuthTypeBearer:
+		return staticAuth("Bearer " + conf.Token)
+	case AuthTypeBasic:
+		return staticAuth("Basic " + base64.StdEncoding.EncodeToString([]byte(conf.UserName+":"+conf.Password)))
+	case AuthTypeOAuth2:
+		return &oauth2Auth{conf: conf, conn: conn}
+	}
+	return nil
+}
+
+// 固定的认证信息
+type staticAuth string
+
+func (s staticAuth) authorize(_ context.Context, r *Request) error {
+	r.Header.Set("Authorization", string(s))
+	return nil
+}
+func (s staticAuth) invalidate() bool { return false }
+
+// 认证中间件, 请求未设置 Authorization 时才会设置, 收到401时重新认证并重试一次.
+// 请求重复发送(重试, 分页, 调用者复用 Request)时, 由认证中间件设置的 Authorization 每次都会重新设置
+func authMiddleware(auth authenticator) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			if v := r.Header.Get("Authorization"); v != "" && v != r.authHeader {
+				return next(ctx, r)
+			}
+			authorize := func() error {
+				if err := auth.authorize(ctx, r); err != nil {
+					return err
+				}
+				r.authHeader = r.Header.Get("Authorization")
+				return nil
+			}
+			if err := authorize(); err != nil {
+				return nil, err
+			}
+			sp, err := next(ctx, r)
+			if err != nil || sp.StatusCode != http.StatusUnauthorized || !r.replayable() || !auth.invalidate() {
+				return sp, err
+			}
+
+			if sp.BodyStream != nil {
+				_ = sp.BodyStream.Close()
+			}
+			if err = authorize(); err != nil {
+				return nil, err
+			}
+			return next(ctx, r)
+		}
+	}
+}
+
+// oauth2 client credentials 认证
+type oauth2Auth struct {
+	conf *AuthConfig
+	conn *clientConn
+
+	mx     sync.Mutex
+	token  string // 包含类型, 如 Bearer xxx
+	expiry time.Time
+	flight *tokenFlight // 正在进行的刷新
+}
+
+// 一次token刷新, 同一时间只会有一个刷新, 其它请求等待它的结果
+type tokenFlight struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+func (o *oauth2Auth) authorize(ctx context.Context, r *Request) error {
+	token, err := o.getToken(ctx)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Authorization", token)
+	return nil
+}
+
+func (o *oauth2Auth) invalidate() bool {
+	o.mx.Lock()
+	defer o.mx.Unlock()
+	o.token = ""
+	return true
+}
+
+func (o *oauth2Auth) getToken(ctx context.Context) (string, error) {
+	o.mx.Lock()
+	refreshBefore := time.Duration(o.conf.RefreshBefore) * time.Millisecond
+	if o.token != "" && time.Now().Add(refreshBefore).Before(o.expiry) {
+		token := o.token
+		o.mx.Unlock()
+		return token, nil
+	}
+
+	f := o.flight
+	if f == nil {
+		f = &tokenFlight{done: make(chan struct{})}
+		o.flight = f
+		go o.refresh(context.WithoutCancel(ctx), f)
+	}
+	o.mx.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-f.done:
+		return f.token, f.err
+	}
+}
+
+func (o *oauth2Auth) refresh(ctx context.Context, f *tokenFlight) {
+	token, expiry, err := o.fetchToken(ctx)
+
+	o.mx.Lock()
+	if err == nil {
+		o.token, o.expiry = token, expiry
+	}
+	o.flight = nil
+	o.mx.Unlock()
+
+	f.token, f.err = token, err
+	close(f.done)
+}
+
+type oauth2TokenReply struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// 获取token, 不经过 zapp filter 和中间件
+func (o *oauth2Auth) fetchToken(ctx context.Context) (string, time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(o.conf.TokenTimeout)*time.Millisecond)
+	defer cancel()
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(o.conf.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.conf.Scopes, " "))
+	}
+	if o.conf.AuthInParams {
+		form.Set("client_id", o.conf.ClientId)
+		form.Set("client_secret", o.conf.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(saveProxy2Ctx(ctx, o.conn.conf.Proxy), http.MethodPost, o.conf.TokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !o.conf.AuthInParams {
+		req.SetBasicAuth(url.QueryEscape(o.conf.ClientId), url.QueryEscape(o.conf.ClientSecret))
+	}
+
+	client, err := o.conn.getClient(&Request{})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	rsp, err := client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("获取oauth2 token失败: %v", err)
+	}
+	defer rsp.Body.Close()
+	body, err := readAllLimit(rsp.Body, 1<<20)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("获取oauth2 token失败: %v", err)
+	}
+
+	var reply oauth2TokenReply
+	_ = sonic.Unmarshal(body, &reply)
+	if rsp.StatusCode != http.StatusOK || reply.AccessToken == "" {
+		if reply.Error != "" {
+			return "", time.Time{}, fmt.Errorf("获取oauth2 token失败: %s %s: %s", rsp.Status, reply.Error, reply.ErrorDescription)
+		}
+		return "", time.Time{}, fmt.Errorf("获取oauth2 token失败: %s", rsp.Status)
+	}
+
+	tokenType := reply.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	expiry := time.Now().Add(time.Duration(reply.ExpiresIn) * time.Second)
+	if reply.ExpiresIn <= 0 {
+		expiry = time.Now().Add(time.Hour)
+	}
+	return tokenType + " " + reply.AccessToken, expiry, nil
+}
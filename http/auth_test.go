@@ -0,0 +1,81 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// 重复发送同一个请求时, 过期的token收到401后会刷新
+func TestAuthReusedRequest(t *testing.T) {
+	var issued, valid int32 // 已签发的token数, 当前有效的token编号
+	tokenSrv := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		n := atomic.AddInt32(&issued, 1)
+		atomic.StoreInt32(&valid, n)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"t%d","expires_in":3600}`, n)
+	}))
+	defer tokenSrv.Close()
+	var auths []string
+	apiSrv := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		auths = append(auths, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != fmt.Sprintf("Bearer t%d", atomic.LoadInt32(&valid)) {
+			w.WriteHeader(stdhttp.StatusUnauthorized)
+		}
+	}))
+	defer apiSrv.Close()
+
+	conf := newConfig()
+	conf.Auth = AuthConfig{Type: AuthTypeOAuth2, TokenUrl: tokenSrv.URL, ClientId: "id", ClientSecret: "secret"}
+	if err := conf.Check(); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClientWithRoundTripper("test-auth-reuse", conf, stdhttp.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+
+	ctx := WithoutZAppFilter(context.Background())
+	req := NewRequest(stdhttp.MethodGet, apiSrv.URL, "")
+	tests := []struct {
+		name      string
+		expire    bool // 发送前使当前token失效
+		wantAuths []string
+	}{
+		{name: "first", wantAuths: []string{"Bearer t1"}},
+		{name: "reuse cached token", wantAuths: []string{"Bearer t1"}},
+		{name: "refresh expired token", expire: true, wantAuths: []string{"Bearer t1", "Bearer t2"}},
+		{name: "reuse refreshed token", wantAuths: []string{"Bearer t2"}},
+	}
+	for _, tt := range tests {
+		if tt.expire {
+			atomic.StoreInt32(&valid, 0)
+		}
+		auths = nil
+		sp, err := c.Do(ctx, req)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if sp.StatusCode != stdhttp.StatusOK {
+			t.Errorf("%s: status = %d, want 200", tt.name, sp.StatusCode)
+		}
+		if fmt.Sprint(auths) != fmt.Sprint(tt.wantAuths) {
+			t.Errorf("%s: Authorization = %q, want %q", tt.name, auths, tt.wantAuths)
+		}
+	}
+
+	// 调用者设置的 Authorization 不会被覆盖
+	auths = nil
+	req = NewRequest(stdhttp.MethodGet, apiSrv.URL, "")
+	req.Header = Header{"Authorization": {"Bearer mine"}}
+	if _, err = c.Do(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if len(auths) != 1 || auths[0] != "Bearer mine" {
+		t.Errorf("Authorization = %q, want [Bearer mine]", auths)
+	}
+}
@@ -0,0 +1,239 @@
+package http
+
+import (
+	"context"
+	"hash/crc32"
+	"io"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// 负载均衡策略
+const (
+	BalancerRoundRobin     = "round-robin"
+	BalancerRandom         = "random"
+	BalancerLeastInflight  = "least-inflight"
+	BalancerConsistentHash = "consistent-hash"
+)
+
+// 一致性hash每个地址的虚拟节点数
+const consistentHashReplicas = 100
+
+type endpoint struct {
+	baseUrl  string
+	inflight atomic.Int64
+}
+
+type ringNode struct {
+	hash uint32
+	ep   *endpoint
+}
+
+// 多地址负载均衡
+type balancer struct {
+	strategy   string
+	hashHeader string
+	endpoints  []*endpoint
+	next       atomic.Uint64
+	ring       []ringNode // 一致性hash环, 按hash排序
+}
+
+func newBalancer(conf *HttpConfig) *balancer {
+	if len(conf.Endpoints) == 0 {
+		return nil
+	}
+
+	b := &balancer{
+		strategy:   conf.Balancer,
+		hashHeader: conf.HashHeader,
+	}
+	for _, u := range conf.Endpoints {
+		b.endpoints = append(b.endpoints, &endpoint{baseUrl: u})
+	}
+	if b.strategy == BalancerConsistentHash {
+		for _, ep := range b.endpoints {
+			for i := 0; i < consistentHashReplicas; i++ {
+				h := crc32.ChecksumIEEE([]byte(ep.baseUrl + "#" + strconv.Itoa(i)))
+				b.ring = append(b.ring, ringNode{hash: h, ep: ep})
+			}
+		}
+		sort.Slice(b.ring, func(i, j int) bool { return b.ring[i].hash < b.ring[j].hash })
+	}
+	return b
+}
+
+// 选择一个地址, exclude 不为nil时尽量避开它
+func (b *balancer) pick(r *Request, exclude *endpoint) *endpoint {
+	if len(b.endpoints) == 1 {
+		return b.endpoints[0]
+	}
+
+	switch b.strategy {
+	case BalancerRandom:
+		for {
+			ep := b.endpoints[rand.IntN(len(b.endpoints))]
+			if ep != exclude {
+				return ep
+			}
+		}
+	case BalancerLeastInflight:
+		var best *endpoint
+		for _, ep := range b.endpoints {
+			if ep == exclude {
+				continue
+			}
+			if best == nil || ep.inflight.Load() < best.inflight.Load() {
+				best = ep
+			}
+		}
+		return best
+	case BalancerConsistentHash:
+		if key := r.Header.Get(b.hashHeader); key != "" {
+			h := crc32.ChecksumIEEE([]byte(key))
+			i := sort.Search(len(b.ring), func(i int) bool { return b.ring[i].hash >= h })
+			for n := 0; n < len(b.ring); n++ {
+				ep := b.ring[(i+n)%len(b.ring)].ep
+				if ep != exclude {
+					return ep
+				}
+			}
+		}
+	}
+
+	// round-robin, 一致性hash没有key时也使用它
+	for {
+		ep := b.endpoints[int(b.next.Add(1)-1)%len(b.endpoints)]
+		if ep != exclude {
+			return ep
+		}
+	}
+}
+
+// 将请求指向这个地址, 返回请求结束后需要调用的函数
+func (ep *endpoint) use(r *Request) (done func()) {
+	r.Path = joinBaseUrl(ep.baseUrl, r.relPath)
+	ep.inflight.Add(1)
+	return func() { ep.inflight.Add(-1) }
+}
+
+type hedgeResult struct {
+	idx int
+	sp  *Response
+	err error
+}
+
+// 按负载均衡选择地址发送, 开启对冲请求时, 首个请求超过延迟阈值仍未返回会向另一个地址发送第二个请求, 使用先成功的结果并取消另一个.
+// 对冲在 zapp filter 内部进行, filter 只会看到一次请求
+func (c *cli) sendBalanced(ctx context.Context, conn *clientConn, r *Request) (*Response, error) {
+	handle := func(ctx context.Context, r *Request) (*Response, error) {
+		return c.handle(ctx, conn, r)
+	}
+	lb := conn.lb
+	if lb == nil || r.relPath == "" {
+		return c.send(ctx, conn, r, handle)
+	}
+
+	ep := lb.pick(r, nil)
+	hedgeDelay := time.Duration(conn.conf.HedgeDelay) * time.Millisecond
+	// 流式响应会被两个请求同时处理, 不进行对冲
+	if hedgeDelay <= 0 || len(lb.endpoints) < 2 || !r.replayable() || !isIdempotentMethod(r.Method) ||
+		r.outStream != nil || r.OutIsStream {
+		defer ep.use(r)()
+		return c.send(ctx, conn, r, handle)
+	}
+
+	r.Path = joinBaseUrl(ep.baseUrl, r.relPath)
+	return c.send(ctx, conn, r, func(ctx context.Context, r *Request) (*Response, error) {
+		return c.hedge(ctx, conn, r, ep, hedgeDelay)
+	})
+}
+
+// 对冲请求, 只有没有错误且状态码不是5xx的响应可以胜出, 都失败时返回最后一个结果
+func (c *cli) hedge(ctx context.Context, conn *clientConn, r *Request, ep *endpoint, hedgeDelay time.Duration) (*Response, error) {
+	lb := conn.lb
+
+	// 首个请求发出后会被并发修改, 先保存一份用于对冲请求
+	base := *r
+	base.Header = r.Header.Clone()
+
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	launch := func(r *Request, ep *endpoint) {
+		ctx, cancel := context.WithCancel(ctx)
+		idx := len(cancels)
+		cancels = append(cancels, cancel)
+		done := ep.use(r)
+		go func() {
+			sp, err := c.handle(ctx, conn, r)
+			done()
+			results <- hedgeResult{idx: idx, sp: sp, err: err}
+		}()
+	}
+	launch(r, ep)
+
+	pending := 1
+	timer := time.NewTimer(hedgeDelay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			hedge := base
+			hedge.Header = base.Header.Clone()
+			launch(&hedge, lb.pick(&base, ep))
+			pending++
+			continue
+		case res := <-results:
+			pending--
+			failed := res.err != nil || (res.sp != nil && res.sp.StatusCode >= 500)
+			if failed && pending > 0 {
+				if res.sp != nil && res.sp.BodyStream != nil {
+					_ = res.sp.BodyStream.Close()
+				}
+				cancels[res.idx]()
+				continue
+			}
+
+			for i, cancel := range cancels {
+				if i != res.idx {
+					cancel()
+				}
+			}
+			if pending > 0 {
+				go drainHedgeLoser(results)
+			}
+			return withCancel(res.sp, res.err, cancels[res.idx])
+		}
+	}
+}
+
+// 返回结果, 流式响应在关闭body时才取消ctx
+func withCancel(sp *Response, err error, cancel context.CancelFunc) (*Response, error) {
+	if sp != nil && sp.BodyStream != nil {
+		sp.BodyStream = &cancelReadCloser{ReadCloser: sp.BodyStream, cancel: cancel}
+		return sp, err
+	}
+	cancel()
+	return sp, err
+}
+
+// 清理已取消的对冲请求的结果
+func drainHedgeLoser(results chan hedgeResult) {
+	res := <-results
+	if res.sp != nil && res.sp.BodyStream != nil {
+		_ = res.sp.BodyStream.Close()
+	}
+}
+
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelReadCloser) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,229 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 熔断器处于打开状态时请求会直接返回这个错误
+var ErrCircuitOpen = errors.New("http: circuit breaker is open")
+
+// 熔断器状态
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = iota // 关闭, 请求正常通过
+	CircuitOpen                         // 打开, 请求直接失败
+	CircuitHalfOpen                     // 半开, 允许少量探测请求通过
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// 熔断器状态统计
+type BreakerStats struct {
+	Client   string       // 客户端名
+	Host     string       // 请求的host
+	State    CircuitState // 当前状态
+	Requests int64        // 当前统计窗口内的请求数
+	Failures int64        // 当前统计窗口内的失败数
+	OpenedAt time.Time    // 最后一次打开的时间
+}
+
+type breakerKey struct {
+	client string
+	conn   uint64 // 连接编号, 隔离 NewClientWithRoundTripper 创建的同名客户端
+	host   string
+}
+
+// 所有熔断器, 按 (客户端名, 连接编号, host) 区分
+var breakers sync.Map // breakerKey -> *breaker
+
+// 获取所有熔断器的状态, 按客户端名和host排序
+func GetBreakerStats() []BreakerStats {
+	var ret []BreakerStats
+	breakers.Range(func(key, value any) bool {
+		ret = append(ret, value.(*breaker).stats())
+		return true
+	})
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Client != ret[j].Client {
+			return ret[i].Client < ret[j].Client
+		}
+		return ret[i].Host < ret[j].Host
+	})
+	return ret
+}
+
+// 获取熔断器, 未启用熔断时返回nil
+func getBreaker(key breakerKey, conf *BreakerConfig) *breaker {
+	if !conf.Enable {
+		return nil
+	}
+	if v, ok := breakers.Load(key); ok {
+		return v.(*breaker)
+	}
+	v, _ := breakers.LoadOrStore(key, newBreaker(key, conf))
+	return v.(*breaker)
+}
+
+// 删除客户端的所有熔断器
+func removeBreakers(client string, conn uint64) {
+	breakers.Range(func(key, value any) bool {
+		if k := key.(breakerKey); k.client == client && k.conn == conn {
+			breakers.Delete(key)
+		}
+		return true
+	})
+}
+
+type breaker struct {
+	key          breakerKey
+	window       time.Duration
+	minRequests  int64
+	failureRatio float64
+	openTimeout  time.Duration
+	halfOpenMax  int64
+
+	mx          sync.Mutex
+	state       CircuitState
+	windowStart time.Time
+	requests    int64
+	failures    int64
+	openedAt    time.Time
+	halfOpenReq int64 // 半开状态已放行的请求数
+	halfOpenOk  int64 // 半开状态成功的请求数
+}
+
+func newBreaker(key breakerKey, conf *BreakerConfig) *breaker {
+	return &breaker{
+		key:          key,
+		window:       time.Duration(conf.Window) * time.Millisecond,
+		minRequests:  int64(conf.MinRequests),
+		failureRatio: conf.FailureRatio,
+		openTimeout:  time.Duration(conf.OpenTimeout) * time.Millisecond,
+		halfOpenMax:  int64(conf.HalfOpenMaxRequests),
+		windowStart:  time.Now(),
+	}
+}
+
+// 请求前检查是否允许通过
+func (b *breaker) allow() error {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	now := time.Now()
+	switch b.state {
+	case CircuitOpen:
+		if now.Sub(b.openedAt) < b.openTimeout {
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+		b.halfOpenReq, b.halfOpenOk = 0, 0
+		fallthrough
+	case CircuitHalfOpen:
+		if b.halfOpenReq >= b.halfOpenMax {
+			return ErrCircuitOpen
+		}
+		b.halfOpenReq++
+		return nil
+	}
+
+	if now.Sub(b.windowStart) >= b.window {
+		b.resetWindow(now)
+	}
+	return nil
+}
+
+// 记录请求结果
+func (b *breaker) report(success bool) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	now := time.Now()
+	switch b.state {
+	case CircuitOpen:
+		return
+	case CircuitHalfOpen:
+		if !success {
+			b.open(now)
+			return
+		}
+		b.halfOpenOk++
+		if b.halfOpenOk >= b.halfOpenMax {
+			b.state = CircuitClosed
+			b.resetWindow(now)
+		}
+		return
+	}
+
+	if now.Sub(b.windowStart) >= b.window {
+		b.resetWindow(now)
+	}
+	b.requests++
+	if !success {
+		b.failures++
+	}
+	if b.requests >= b.minRequests && float64(b.failures)/float64(b.requests) >= b.failureRatio {
+		b.open(now)
+	}
+}
+
+// 请求被调用者取消, 不计入统计, 只释放半开状态占用的探测名额
+func (b *breaker) release() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.state == CircuitHalfOpen && b.halfOpenReq > 0 {
+		b.halfOpenReq--
+	}
+}
+
+// 根据请求结果记录, 网络错误和5xx状态码视为失败
+func (b *breaker) done(err error, statusCode int) {
+	if errors.Is(err, context.Canceled) {
+		b.release()
+		return
+	}
+	b.report(err == nil && statusCode < http.StatusInternalServerError)
+}
+
+func (b *breaker) open(now time.Time) {
+	b.state = CircuitOpen
+	b.openedAt = now
+	b.resetWindow(now)
+}
+
+func (b *breaker) resetWindow(now time.Time) {
+	b.windowStart = now
+	b.requests, b.failures = 0, 0
+}
+
+func (b *breaker) stats() BreakerStats {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	state := b.state
+	if state == CircuitOpen && time.Since(b.openedAt) >= b.openTimeout {
+		state = CircuitHalfOpen
+	}
+	return BreakerStats{
+		Client:   b.key.client,
+		Host:     b.key.host,
+		State:    state,
+		Requests: b.requests,
+		Failures: b.failures,
+		OpenedAt: b.openedAt,
+	}
+}
@@ -0,0 +1,232 @@
+package http
+
+import (
+	"context"
+	"errors"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestBreaker(halfOpenMax int) *breaker {
+	return newBreaker(breakerKey{client: "test"}, &BreakerConfig{
+		Enable:              true,
+		Window:              60000,
+		MinRequests:         4,
+		FailureRatio:        0.5,
+		OpenTimeout:         20,
+		HalfOpenMaxRequests: halfOpenMax,
+	})
+}
+
+// 发送请求并记录结果, 返回 allow 的错误
+func breakerCall(b *breaker, err error, statusCode int) error {
+	if e := b.allow(); e != nil {
+		return e
+	}
+	b.done(err, statusCode)
+	return nil
+}
+
+// 使熔断器打开并等待进入半开状态
+func openBreaker(t *testing.T, b *breaker) {
+	t.Helper()
+	for i := 0; i < 4; i++ {
+		_ = breakerCall(b, nil, stdhttp.StatusInternalServerError)
+	}
+	if got := b.stats().State; got != CircuitOpen {
+		t.Fatalf("state = %v, want open", got)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if got := b.stats().State; got != CircuitHalfOpen {
+		t.Fatalf("state = %v, want half-open", got)
+	}
+}
+
+func TestBreakerOpen(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []int // 状态码, 0表示网络错误
+		want    CircuitState
+	}{
+		{name: "below min requests", results: []int{500, 500, 500}, want: CircuitClosed},
+		{name: "below failure ratio", results: []int{200, 200, 200, 500}, want: CircuitClosed},
+		{name: "reach failure ratio", results: []int{200, 200, 500, 500}, want: CircuitOpen},
+		{name: "network errors", results: []int{0, 0, 200, 0}, want: CircuitOpen},
+		{name: "4xx is success", results: []int{404, 400, 429, 500}, want: CircuitClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBreaker(1)
+			for _, code := range tt.results {
+				var err error
+				if code == 0 {
+					err = errors.New("connection refused")
+				}
+				if e := breakerCall(b, err, code); e != nil {
+					t.Fatalf("allow: %v", e)
+				}
+			}
+			if got := b.stats().State; got != tt.want {
+				t.Errorf("state = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakerOpenRejects(t *testing.T) {
+	b := newTestBreaker(1)
+	for i := 0; i < 4; i++ {
+		_ = breakerCall(b, nil, stdhttp.StatusBadGateway)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow err = %v, want ErrCircuitOpen", err)
+	}
+	// 取消的请求不计入统计
+	b2 := newTestBreaker(1)
+	for i := 0; i < 4; i++ {
+		_ = breakerCall(b2, context.Canceled, 0)
+	}
+	if got := b2.stats(); got.State != CircuitClosed || got.Requests != 0 {
+		t.Errorf("canceled requests are counted: %+v", got)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name        string
+		halfOpenMax int
+		probes      []int // 探测请求的状态码
+		want        CircuitState
+	}{
+		{name: "probe success closes", halfOpenMax: 1, probes: []int{200}, want: CircuitClosed},
+		{name: "probe failure reopens", halfOpenMax: 1, probes: []int{503}, want: CircuitOpen},
+		{name: "all probes must succeed", halfOpenMax: 2, probes: []int{200}, want: CircuitHalfOpen},
+		{name: "two probes succeed", halfOpenMax: 2, probes: []int{200, 204}, want: CircuitClosed},
+		{name: "second probe fails", halfOpenMax: 2, probes: []int{200, 500}, want: CircuitOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBreaker(tt.halfOpenMax)
+			openBreaker(t, b)
+			for _, code := range tt.probes {
+				if err := breakerCall(b, nil, code); err != nil {
+					t.Fatalf("probe rejected: %v", err)
+				}
+			}
+			if got := b.stats().State; got != tt.want {
+				t.Errorf("state = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakerHalfOpenProbeLimit(t *testing.T) {
+	b := newTestBreaker(1)
+	openBreaker(t, b)
+
+	if err := b.allow(); err != nil {
+		t.Fatalf("first probe rejected: %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second probe err = %v, want ErrCircuitOpen", err)
+	}
+	// 取消的探测请求释放名额
+	b.done(context.Canceled, 0)
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe rejected after release: %v", err)
+	}
+	b.done(nil, stdhttp.StatusOK)
+	if got := b.stats().State; got != CircuitClosed {
+		t.Errorf("state = %v, want closed", got)
+	}
+}
+
+func TestBreakerClient(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		hits++
+		w.WriteHeader(stdhttp.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	conf := newConfig()
+	conf.Breaker = BreakerConfig{Enable: true, Window: 60000, MinRequests: 2, FailureRatio: 0.5, OpenTimeout: 60000, HalfOpenMaxRequests: 1}
+	if err := conf.Check(); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClientWithRoundTripper("test-breaker-client", conf, stdhttp.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+
+	ctx := WithoutZAppFilter(context.Background())
+	for i := 0; i < 2; i++ {
+		_, _ = c.Get(ctx, srv.URL)
+	}
+	if _, err = c.Get(ctx, srv.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if hits != 2 {
+		t.Errorf("server hits = %d, want 2", hits)
+	}
+}
+
+// NewClientWithRoundTripper 创建的同名客户端不共享熔断器, Close 后释放熔断器和har文件
+func TestBreakerClientIsolated(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		hits++
+		w.WriteHeader(stdhttp.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	conf := newConfig()
+	conf.Breaker = BreakerConfig{Enable: true, Window: 60000, MinRequests: 2, FailureRatio: 0.5, OpenTimeout: 60000, HalfOpenMaxRequests: 1}
+	conf.Har = HarConfig{Enable: true, Path: filepath.Join(t.TempDir(), "test.har")}
+	if err := conf.Check(); err != nil {
+		t.Fatal(err)
+	}
+	newClient := func() *cli {
+		c, err := NewClientWithRoundTripper("test-breaker-isolated", conf, stdhttp.DefaultTransport)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(c.Close)
+		return c.(*cli)
+	}
+	a, b := newClient(), newClient()
+
+	ctx := WithoutZAppFilter(context.Background())
+	for i := 0; i < 3; i++ {
+		_, _ = a.Get(ctx, srv.URL)
+	}
+	if _, err := b.Get(ctx, srv.URL); errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("breaker is shared between clients with the same name")
+	}
+	if hits != 3 {
+		t.Errorf("server hits = %d, want 3", hits)
+	}
+
+	key := breakerKey{client: a.Name, conn: a.conn.id, host: strings.TrimPrefix(srv.URL, "http://")}
+	if _, ok := breakers.Load(key); !ok {
+		t.Fatal("breaker not found")
+	}
+	if a.conn.har.f == nil {
+		t.Fatal("har file is not opened")
+	}
+	a.Close()
+	if _, ok := breakers.Load(key); ok {
+		t.Error("breaker is not removed after Close")
+	}
+	if a.conn.har.f != nil {
+		t.Error("har file is not closed after Close")
+	}
+	if _, ok := breakers.Load(breakerKey{client: b.Name, conn: b.conn.id, host: key.host}); !ok {
+		t.Error("Close removes breakers of another client")
+	}
+}
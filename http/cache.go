@@ -0,0 +1,318 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// 响应的缓存状态, 记录在 Response.Cache 中, zapp filter 可以看到
+const (
+	CacheHit         = "HIT"         // 直接使用缓存
+	CacheMiss        = "MISS"        // 没有可用的缓存, 已发送请求
+	CacheRevalidated = "REVALIDATED" // 缓存已过期, 服务器返回304确认缓存依然有效
+)
+
+// 缓存存储
+type CacheStorage interface {
+	// 获取缓存, 不存在时返回 ok=false
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// 设置缓存, ttl 为缓存的保存时间
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// 删除缓存
+	Del(ctx context.Context, key string) error
+}
+
+// 为客户端设置的缓存存储, 优先级高于配置的存储
+var cacheStorages sync.Map // 客户端名 -> CacheStorage
+
+// 为客户端设置缓存存储, 如 NewRedisCacheStorage, 客户端的 Cache.Enable 为 true 时才会使用缓存
+func SetCacheStorage(name string, storage CacheStorage) {
+	cacheStorages.Store(name, storage)
+}
+
+// 可以缓存的响应状态码
+var cacheableStatus = []int{
+	http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+	http.StatusMovedPermanently, http.StatusPermanentRedirect, http.StatusNotFound, http.StatusGone,
+}
+
+// 缓存的响应
+type cacheEntry struct {
+	Status       string
+	StatusCode   int
+	Header       Header
+	Body         []byte
+	RequestTime  time.Time           // 发送请求的时间
+	ResponseTime time.Time           // 收到响应的时间
+	Vary         map[string][]string // Vary 中的请求header和它们的值
+}
+
+// 响应缓存
+type httpCache struct {
+	name    string
+	conf    *CacheConfig
+	storage CacheStorage
+}
+
+func newHttpCache(name string, conf *CacheConfig) (*httpCache, error) {
+	if !conf.Enable {
+		return nil, nil
+	}
+	c := &httpCache{name: name, conf: conf}
+	switch conf.Storage {
+	case CacheStorageMemory:
+		c.storage = NewMemoryCacheStorage(conf.MaxEntries)
+	case CacheStorageDisk:
+		storage, err := NewDiskCacheStorage(conf.Dir)
+		if err != nil {
+			return nil, err
+		}
+		c.storage = storage
+	}
+	return c, nil
+}
+
+// 获取缓存存储, shared 表示使用 SetCacheStorage 设置的存储, 如redis可能由多个进程共享
+func (c *httpCache) getStorage() (storage CacheStorage, shared bool) {
+	if v, ok := cacheStorages.Load(c.name); ok {
+		return v.(CacheStorage), true
+	}
+	return c.storage, false
+}
+
+// 缓存key, 客户端配置了多个地址时使用相对路径, 以便不同地址共享缓存
+func (c *httpCache) key(r *Request) string {
+	path := r.Path
+	if r.relPath != "" {
+		path = r.relPath
+	}
+	if len(r.Params) > 0 {
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		path += sep + r.Params.Encode()
+	}
+	return "http:cache:" + c.name + ":" + r.Method + " " + path
+}
+
+// 请求是否可以使用缓存, 带有身份信息(Authorization, Cookie, 会话cookie, 签名)的请求不使用缓存, 因为缓存key不区分用户
+func cacheableRequest(r *Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if r.countInBody() > 0 || r.OutIsStream || r.outStream != nil {
+		return false
+	}
+	if r.jar != nil || r.signer != nil || r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+		return false
+	}
+	return !hasCacheDirective(r.Header, "no-store")
+}
+
+// 缓存中间件, 在认证和用户中间件之后, 命中缓存时不会发送请求
+func (c *httpCache) middleware(next Handler) Handler {
+	return func(ctx context.Context, r *Request) (*Response, error) {
+		storage, shared := c.getStorage()
+		if storage == nil || !cacheableRequest(r) {
+			return next(ctx, r)
+		}
+
+		key := c.key(r)
+		entry := c.load(ctx, storage, key, r)
+		if entry != nil && !hasCacheDirective(r.Header, "no-cache") && !hasCacheDirective(entry.Header, "no-cache") &&
+			entry.age(time.Now()) < entry.freshness() {
+			return entry.response(CacheHit), nil
+		}
+
+		// 使用缓存的验证器发送条件请求, 请求中已设置的不会覆盖
+		var conditional bool
+		if entry != nil {
+			if etag := entry.Header.Get("ETag"); etag != "" && r.Header.Get("If-None-Match") == "" {
+				r.Header.Set("If-None-Match", etag)
+				conditional = true
+			}
+			if lm := entry.Header.Get("Last-Modified"); lm != "" && r.Header.Get("If-Modified-Since") == "" {
+				r.Header.Set("If-Modified-Since", lm)
+				conditional = true
+			}
+		}
+
+		reqTime := time.Now()
+		sp, err := next(ctx, r)
+		if conditional {
+			r.Header.Del("If-None-Match")
+			r.Header.Del("If-Modified-Since")
+		}
+		if err != nil {
+			return sp, err
+		}
+
+		if conditional && sp.StatusCode == http.StatusNotModified {
+			// 使用304响应的header更新缓存
+			for k, v := range sp.Header {
+				entry.Header[k] = v
+			}
+			entry.RequestTime, entry.ResponseTime = reqTime, time.Now()
+			c.store(ctx, storage, key, entry)
+			return entry.response(CacheRevalidated), nil
+		}
+
+		sp.Cache = CacheMiss
+		if entry := c.newEntry(r, sp, reqTime, shared); entry != nil {
+			c.store(ctx, storage, key, entry)
+		}
+		return sp, nil
+	}
+}
+
+// 加载缓存, Vary 的请求header不一致时视为没有缓存. 读取失败时不影响请求
+func (c *httpCache) load(ctx context.Context, storage CacheStorage, key string, r *Request) *cacheEntry {
+	data, ok, err := storage.Get(ctx, key)
+	if err != nil || !ok {
+		return nil
+	}
+	var entry cacheEntry
+	if err = sonic.Unmarshal(data, &entry); err != nil {
+		return nil
+	}
+	for name, values := range entry.Vary {
+		if !slices.Equal(r.Header.Values(name), values) {
+			return nil
+		}
+	}
+	return &entry
+}
+
+// 根据响应创建缓存, 不可以缓存时返回nil. 共享存储不缓存 private 的响应
+func (c *httpCache) newEntry(r *Request, sp *Response, reqTime time.Time, shared bool) *cacheEntry {
+	if sp.BodyStream != nil || !slices.Contains(cacheableStatus, sp.StatusCode) {
+		return nil
+	}
+	if hasCacheDirective(sp.Header, "no-store") || (shared && hasCacheDirective(sp.Header, "private")) {
+		return nil
+	}
+	if c.conf.MaxBodyBytes > 0 && int64(len(sp.Body)) > c.conf.MaxBodyBytes {
+		return nil
+	}
+
+	entry := &cacheEntry{
+		Status:       sp.Status,
+		StatusCode:   sp.StatusCode,
+		Header:       sp.Header.Clone(),
+		Body:         []byte(sp.Body),
+		RequestTime:  reqTime,
+		ResponseTime: time.Now(),
+	}
+	// 没有新鲜度信息也没有验证器时缓存没有意义
+	if entry.freshness() <= 0 && entry.Header.Get("ETag") == "" && entry.Header.Get("Last-Modified") == "" {
+		return nil
+	}
+
+	for _, v := range sp.Header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if name == "*" {
+				return nil
+			}
+			if entry.Vary == nil {
+				entry.Vary = make(map[string][]string)
+			}
+			entry.Vary[name] = r.Header.Values(name)
+		}
+	}
+	return entry
+}
+
+// 保存缓存, 有验证器时过期后额外保留 KeepStale 用于重新验证. 保存失败时不影响请求
+func (c *httpCache) store(ctx context.Context, storage CacheStorage, key string, entry *cacheEntry) {
+	ttl := entry.freshness() - entry.age(time.Now())
+	if entry.Header.Get("ETag") != "" || entry.Header.Get("Last-Modified") != "" {
+		ttl += time.Duration(c.conf.KeepStale) * time.Millisecond
+	}
+	if ttl <= 0 {
+		_ = storage.Del(ctx, key)
+		return
+	}
+	data, err := sonic.Marshal(entry)
+	if err != nil {
+		return
+	}
+	_ = storage.Set(ctx, key, data, ttl)
+}
+
+// 新鲜度, 按 max-age 或 Expires 计算, 没有时为0
+func (e *cacheEntry) freshness() time.Duration {
+	if v, ok := cacheDirective(e.Header, "max-age"); ok {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			return time.Duration(n) * time.Second
+		}
+		return 0
+	}
+	if v := e.Header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return 0
+		}
+		date, err := http.ParseTime(e.Header.Get("Date"))
+		if err != nil {
+			date = e.ResponseTime
+		}
+		return expires.Sub(date)
+	}
+	return 0
+}
+
+// 按 rfc7234 计算缓存的当前年龄
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	var apparent time.Duration
+	if date, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		apparent = max(0, e.ResponseTime.Sub(date))
+	}
+	corrected := e.ResponseTime.Sub(e.RequestTime)
+	if n, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil && n > 0 {
+		corrected += time.Duration(n) * time.Second
+	}
+	return max(apparent, corrected) + now.Sub(e.ResponseTime)
+}
+
+func (e *cacheEntry) response(cache string) *Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(e.age(time.Now())/time.Second), 10))
+	return &Response{
+		Body:          string(e.Body),
+		Status:        e.Status,
+		StatusCode:    e.StatusCode,
+		ContentLength: int64(len(e.Body)),
+		Header:        header,
+		Cache:         cache,
+	}
+}
+
+// 获取 Cache-Control 中的指令
+func cacheDirective(header Header, name string) (string, bool) {
+	for _, v := range header.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			k, val, _ := strings.Cut(strings.TrimSpace(d), "=")
+			if strings.EqualFold(k, name) {
+				return strings.Trim(val, `"`), true
+			}
+		}
+	}
+	return "", false
+}
+
+func hasCacheDirective(header Header, name string) bool {
+	_, ok := cacheDirective(header, name)
+	return ok
+}
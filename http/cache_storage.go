@@ -0,0 +1,192 @@
+package http
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 缓存存储类型
+const (
+	CacheStorageMemory = "memory"
+	CacheStorageDisk   = "disk"
+)
+
+type memoryCacheItem struct {
+	key      string
+	value    []byte
+	expireAt time.Time
+}
+
+// 内存lru缓存
+type memoryCacheStorage struct {
+	maxEntries int
+
+	mx    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+// 创建内存lru缓存存储, maxEntries 为最大缓存条目数, 小于1表示不限制
+func NewMemoryCacheStorage(maxEntries int) CacheStorage {
+	return &memoryCacheStorage{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (m *memoryCacheStorage) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	e, ok := m.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	item := e.Value.(*memoryCacheItem)
+	if !time.Now().Before(item.expireAt) {
+		m.remove(e)
+		return nil, false, nil
+	}
+	m.ll.MoveToFront(e)
+	return item.value, true, nil
+}
+
+func (m *memoryCacheStorage) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	item := &memoryCacheItem{key: key, value: value, expireAt: time.Now().Add(ttl)}
+	if e, ok := m.items[key]; ok {
+		e.Value = item
+		m.ll.MoveToFront(e)
+		return nil
+	}
+	m.items[key] = m.ll.PushFront(item)
+	if m.maxEntries > 0 && m.ll.Len() > m.maxEntries {
+		m.remove(m.ll.Back())
+	}
+	return nil
+}
+
+func (m *memoryCacheStorage) Del(_ context.Context, key string) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if e, ok := m.items[key]; ok {
+		m.remove(e)
+	}
+	return nil
+}
+
+func (m *memoryCacheStorage) remove(e *list.Element) {
+	m.ll.Remove(e)
+	delete(m.items, e.Value.(*memoryCacheItem).key)
+}
+
+// 磁盘缓存, 每个key一个文件, 文件名为key的sha256, 文件内容为8字节的过期时间(unix纳秒)加上数据
+type diskCacheStorage struct {
+	dir string
+}
+
+// 创建磁盘缓存存储, 目录不存在时会自动创建
+func NewDiskCacheStorage(dir string) (CacheStorage, error) {
+	if dir == "" {
+		return nil, errors.New("磁盘缓存目录为空")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建磁盘缓存目录失败: %v", err)
+	}
+	return &diskCacheStorage{dir: dir}, nil
+}
+
+func (d *diskCacheStorage) file(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(h[:]))
+}
+
+func (d *diskCacheStorage) Get(_ context.Context, key string) ([]byte, bool, error) {
+	data, err := os.ReadFile(d.file(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(data) < 8 {
+		return nil, false, nil
+	}
+	expireAt := time.Unix(0, int64(binary.BigEndian.Uint64(data)))
+	if !time.Now().Before(expireAt) {
+		_ = os.Remove(d.file(key))
+		return nil, false, nil
+	}
+	return data[8:], true, nil
+}
+
+func (d *diskCacheStorage) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	data := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(data, uint64(time.Now().Add(ttl).UnixNano()))
+	copy(data[8:], value)
+
+	// 先写临时文件再替换, 避免并发读到写了一半的文件
+	tmp, err := os.CreateTemp(d.dir, "*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), d.file(key))
+}
+
+func (d *diskCacheStorage) Del(_ context.Context, key string) error {
+	err := os.Remove(d.file(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// redis缓存
+type redisCacheStorage struct {
+	client redis.UniversalClient
+}
+
+// 使用redis创建缓存存储, 可以使用 github.com/zly-app/component/redis 获取的客户端
+func NewRedisCacheStorage(client redis.UniversalClient) CacheStorage {
+	return &redisCacheStorage{client: client}
+}
+
+func (r *redisCacheStorage) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func (r *redisCacheStorage) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *redisCacheStorage) Del(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
@@ -3,18 +3,18 @@ package http
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"errors"
+	"fmt"
 	"io"
-	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/bytedance/sonic"
 	"github.com/zly-app/zapp/filter"
 	"github.com/zly-app/zapp/pkg/utils"
-	"gopkg.in/yaml.v3"
 )
 
 const DefaultComponentType = "http"
@@ -27,10 +27,22 @@ type Client interface {
 	Patch(ctx context.Context, path string, reqBody []byte, opts ...Option) (*Response, error)
 	Delete(ctx context.Context, path string, reqBody []byte, opts ...Option) (*Response, error)
 	Do(ctx context.Context, req *Request) (*Response, error)
+	// 下载文件到 dstPath, 支持断点续传
+	Download(ctx context.Context, url, dstPath string, opts ...Option) (*Response, error)
+	// 建立websocket连接
+	Dial(ctx context.Context, url string, opts ...Option) (*WSConn, *Response, error)
+	// 添加中间件, 只对当前客户端生效
+	Use(mws ...Middleware)
+	// 释放 NewClientWithRoundTripper 创建的客户端的资源(har文件, 熔断器, 限流器), 根据zapp配置创建的客户端在zapp关闭时释放, 调用无效
+	Close()
 }
 
 type cli struct {
 	Name string
+	conn *clientConn // 不为nil时使用它而不是根据zapp配置创建
+
+	mx  sync.RWMutex
+	mws []Middleware
 }
 
 type Request struct {
@@ -44,17 +56,40 @@ type Request struct {
 	Header Header `json:"Header,omitempty"` // 请求head
 	Params Values `json:"Params,omitempty"` // 请求参数
 
-	Body       string
-	inJsonPtr  interface{} // 输入json
-	inYamlPtr  interface{} // 输入yaml
-	inStream   io.Reader
-	InIsStream bool `json:"InIsStream,omitempty"` // 标记输入body是流数据, 使用者不应该主动设置这个值, 它是http库自动设置的
+	Body        string
+	inCodec     string      // 输入数据的编解码器名
+	inPtr       interface{} // 输入数据
+	inStream    io.Reader
+	inMultipart *Multipart // 输入 multipart/form-data
+	InIsStream  bool       `json:"InIsStream,omitempty"` // 标记输入body是流数据, 使用者不应该主动设置这个值, 它是http库自动设置的
+
+	outCodec    string        // 输出数据的编解码器名, CodecAuto 表示根据响应的 Content-Type 选择
+	outPtr      interface{}   // 输出数据
+	outErrorPtr interface{}   // 响应状态码不符合预期时输出的json错误数据
+	outStream   streamHandler // 流式解析响应数据, 如 sse, ndjson
+	outAccept   string        // 流式解析响应数据时使用的 Accept
+	OutIsStream bool          `json:"OutIsStream,omitempty"` // 标记响应body是流数据
 
-	outJsonPtr  interface{} // 输出json
-	outYamlPtr  interface{} // 输出yaml
-	OutIsStream bool        `json:"OutIsStream,omitempty"` // 标记响应body是流数据
+	MaxResponseBytes int64 `json:"MaxResponseBytes,omitempty"` // 响应body最大字节数, 超过时返回 ErrResponseTooLarge, 0表示使用客户端配置
 
 	Proxy string `json:"Proxy,omitempty"` // 代理地址
+
+	relPath string // 客户端配置了多个地址时的相对路径, 每次发送时根据选择的地址生成 Path
+
+	retry  *RetryPolicy  // 重试策略, 为nil时使用客户端的默认重试策略
+	tls    TLSConfig     // tls配置, 非零值的字段会覆盖客户端的tls配置
+	status *statusPolicy // 响应状态码检查策略, 为nil时使用客户端的默认策略
+
+	download downloadConf   // 下载配置, 仅用于 Download
+	signer   Signer         // 请求签名器
+	jar      http.CookieJar // 会话的cookie jar, 为nil表示不使用cookie
+
+	authHeader string // 认证中间件设置的 Authorization, 请求重复发送时需要重新认证
+
+	inCompress string // 请求body的压缩编码
+	autoDecode bool   // 自动设置了 Accept-Encoding, 需要自动解压响应
+
+	ws wsConf // websocket配置, 仅用于 Dial
 }
 
 type Response struct {
@@ -65,353 +100,438 @@ type Response struct {
 	StatusCode    int
 	ContentLength int64
 	Header        Header
-	Uncompressed  bool `json:"Uncompressed,omitempty"`
+	Uncompressed  bool   `json:"Uncompressed,omitempty"`
+	Cache         string `json:"Cache,omitempty"` // 缓存状态, HIT, MISS, REVALIDATED, 未启用缓存或请求不可缓存时为空
 }
 
 var NewClient = func(name string) Client {
-	c := cli{
+	c := &cli{
 		Name: name,
 	}
 	return c
 }
 
-var rawStdClient = &http.Client{
-	Transport: rawStdTransport,
+// 独立创建的客户端的编号
+var standaloneConnID atomic.Uint64
+
+// 使用指定的配置和 RoundTripper 创建客户端, 不会读取zapp的配置, 一般用于测试或接入自定义的传输层.
+// conf 为nil时使用默认配置, rt 为nil时根据配置创建 http.Transport.
+// 熔断器和限流器不会与同名的客户端共享, 不再使用时需要调用 Close
+func NewClientWithRoundTripper(name string, conf *HttpConfig, rt http.RoundTripper) (Client, error) {
+	if conf == nil {
+		conf = newConfig()
+	}
+	if err := conf.Check(); err != nil {
+		return nil, fmt.Errorf("http客户端<%s>配置错误: %v", name, err)
+	}
+	conn, err := newClientConn(name, conf)
+	if err != nil {
+		return nil, err
+	}
+	conn.rt = rt
+	conn.id = standaloneConnID.Add(1)
+	return &cli{Name: name, conn: conn}, nil
+}
+
+func (c *cli) Close() {
+	if c.conn != nil {
+		c.conn.Close()
+	}
 }
-var rawStdClientInsecureSkipVerify = &http.Client{
-	Transport: rawStdTransportInsecureSkipVerify,
+
+func (c *cli) getConn() (*clientConn, error) {
+	if c.conn != nil {
+		return c.conn, nil
+	}
+	return defCreator.getClientConn(c.Name)
 }
 
-func (c cli) Get(ctx context.Context, path string, opts ...Option) (*Response, error) {
+func (c *cli) Get(ctx context.Context, path string, opts ...Option) (*Response, error) {
 	req := NewRequest(http.MethodGet, path, "")
 	req.applyOptions(opts...)
 	return c.do(ctx, req)
 }
 
-func (c cli) Head(ctx context.Context, path string, opts ...Option) (*Response, error) {
+func (c *cli) Head(ctx context.Context, path string, opts ...Option) (*Response, error) {
 	req := NewRequest(http.MethodHead, path, "")
 	req.applyOptions(opts...)
 	return c.do(ctx, req)
 }
 
-func (c cli) Post(ctx context.Context, path string, reqBody []byte, opts ...Option) (*Response, error) {
+func (c *cli) Post(ctx context.Context, path string, reqBody []byte, opts ...Option) (*Response, error) {
 	req := NewRequest(http.MethodPost, path, string(reqBody))
 	req.applyOptions(opts...)
 	return c.do(ctx, req)
 }
 
-func (c cli) Put(ctx context.Context, path string, reqBody []byte, opts ...Option) (*Response, error) {
+func (c *cli) Put(ctx context.Context, path string, reqBody []byte, opts ...Option) (*Response, error) {
 	req := NewRequest(http.MethodPut, path, string(reqBody))
 	req.applyOptions(opts...)
 	return c.do(ctx, req)
 }
 
-func (c cli) Patch(ctx context.Context, path string, reqBody []byte, opts ...Option) (*Response, error) {
+func (c *cli) Patch(ctx context.Context, path string, reqBody []byte, opts ...Option) (*Response, error) {
 	req := NewRequest(http.MethodPatch, path, string(reqBody))
 	req.applyOptions(opts...)
 	return c.do(ctx, req)
 }
 
-func (c cli) Delete(ctx context.Context, path string, reqBody []byte, opts ...Option) (*Response, error) {
+func (c *cli) Delete(ctx context.Context, path string, reqBody []byte, opts ...Option) (*Response, error) {
 	req := NewRequest(http.MethodDelete, path, string(reqBody))
 	req.applyOptions(opts...)
 	return c.do(ctx, req)
 }
-func (c cli) Do(ctx context.Context, req *Request) (*Response, error) {
+func (c *cli) Do(ctx context.Context, req *Request) (*Response, error) {
 	return c.do(ctx, req)
 }
 
-func (c cli) do(ctx context.Context, r *Request) (*Response, error) {
-	if r.Body != "" && (r.inStream != nil || r.inJsonPtr != nil || r.inYamlPtr != nil) {
-		return nil, errors.New("Body, inJsonPtr, inYamlPtr and inStream are mutually exclusive")
-	}
-	if r.inStream != nil && (r.inJsonPtr != nil || r.inYamlPtr != nil) {
-		return nil, errors.New("Body, inJsonPtr, inYamlPtr and inStream are mutually exclusive")
-	}
-	if r.inJsonPtr != nil && r.inYamlPtr != nil {
-		return nil, errors.New("Body, inJsonPtr, inYamlPtr and inStream are mutually exclusive")
+func (c *cli) do(ctx context.Context, r *Request) (*Response, error) {
+	conn, err := c.getConn()
+	if err != nil {
+		return nil, err
 	}
+	conn.applyDefault(r)
 
-	if r.outJsonPtr != nil && r.outYamlPtr != nil {
-		return nil, errors.New("outJsonPtr and outYamlPtr are mutually exclusive")
+	if r.countInBody() > 1 {
+		return nil, errors.New("Body, inPtr, inMultipart and inStream are mutually exclusive")
 	}
 
-	r.InIsStream = r.inStream != nil
-	if r.Body != "" {
-		r.inStream = bytes.NewBufferString(r.Body)
+	if r.Header == nil {
+		r.Header = make(http.Header)
 	}
-	if r.inJsonPtr != nil {
-		body, err := sonic.ConfigStd.Marshal(r.inJsonPtr)
+
+	r.InIsStream = r.inStream != nil || r.inMultipart != nil
+	if r.inPtr != nil {
+		codec, err := getCodec(r.inCodec)
+		if err != nil {
+			return nil, err
+		}
+		body, err := codec.Marshal(r.inPtr)
 		if err != nil {
 			return nil, err
 		}
 		r.Body = string(body)
-		r.inStream = bytes.NewReader(body)
+		setHeaderIfAbsent(r.Header, "Content-Type", codec.ContentType())
+	}
+	if r.inMultipart != nil {
+		setHeaderIfAbsent(r.Header, "Content-Type", r.inMultipart.ContentType())
+	}
+	if r.inCompress != "" {
+		if err = checkEncoding(r.inCompress); err != nil {
+			return nil, err
+		}
+	}
+	// Range 请求不自动压缩, 以免断点续传的偏移量对应的是压缩后的数据.
+	// Accept-Encoding 只在发送时设置, 不写入 r.Header, 以便重复发送请求时作出相同的判断
+	r.autoDecode = !conn.conf.DisableCompression && r.Header.Get("Accept-Encoding") == "" && r.Header.Get("Range") == ""
+	if r.outStream != nil {
+		if r.outPtr != nil || r.OutIsStream {
+			return nil, errors.New("outStream, outPtr and OutIsStream are mutually exclusive")
+		}
+		setHeaderIfAbsent(r.Header, "Accept", r.outAccept)
 	}
-	if r.inYamlPtr != nil {
-		body, err := yaml.Marshal(r.inJsonPtr)
+	if r.outPtr != nil && r.outCodec != CodecAuto {
+		codec, err := getCodec(r.outCodec)
 		if err != nil {
 			return nil, err
 		}
-		r.Body = string(body)
-		r.inStream = bytes.NewReader(body)
+		setHeaderIfAbsent(r.Header, "Accept", codec.ContentType())
 	}
 
 	// 附加trace
-	if r.Header == nil {
-		r.Header = make(http.Header)
-	}
 	utils.Trace.SaveToHeaders(ctx, r.Header)
 
-	if isWithoutZAppFilter(ctx) {
-		sp, err := c._do(ctx, r)
-		if err == nil {
-			err = c.unmarshal(ctx, r, sp)
-		}
+	sp, err := c.sendWithRetry(ctx, conn, r)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.checkStatus(conn, r, sp); err != nil {
 		return sp, err
 	}
+	err = c.unmarshal(ctx, r, sp)
+	return sp, err
+}
+
+// 设置了几种请求body
+func (r *Request) countInBody() int {
+	n := 0
+	for _, ok := range []bool{r.Body != "", r.inPtr != nil, r.inMultipart != nil, r.inStream != nil} {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
+
+// 请求body是否可以重复发送
+func (r *Request) replayable() bool {
+	return r.inStream == nil && (r.inMultipart == nil || r.inMultipart.replayable())
+}
+
+// 将请求参数追加到url的参数中
+func addParams(u *url.URL, params Values) {
+	if len(params) == 0 {
+		return
+	}
+	query := u.Query()
+	for k, v := range params {
+		query[k] = append(query[k], v...)
+	}
+	u.RawQuery = query.Encode()
+}
+
+func setHeaderIfAbsent(header Header, key, value string) {
+	if header.Get(key) == "" {
+		header.Set(key, value)
+	}
+}
 
-	ctx, chain := filter.GetClientFilter(ctx, DefaultComponentType, c.Name, r.Method)
+// 经过 zapp filter 后调用 next, 调用 next 前将主调信息附加到 header. callersSkip 为调用方到客户端方法的层数. 测试时可以替换
+var handleClientFilter = func(ctx context.Context, name, method string, callersSkip int, header Header, req interface{},
+	next func(ctx context.Context, req interface{}) (interface{}, error)) (interface{}, error) {
+	ctx, chain := filter.GetClientFilter(ctx, DefaultComponentType, name, method)
 	meta := filter.GetCallMeta(ctx)
-	meta.AddCallersSkip(1)
+	meta.AddCallersSkip(callersSkip + 1) // 包含 handleClientFilter
 
-	rsp, err := chain.Handle(ctx, r, func(ctx context.Context, req interface{}) (rsp interface{}, err error) {
+	return chain.Handle(ctx, req, func(ctx context.Context, req interface{}) (rsp interface{}, err error) {
 		// 附加主调信息
 		meta := filter.GetCallMeta(ctx)
-		filter.SaveCallerMeta2Header(r.Header, filter.CallerMeta{
+		filter.SaveCallerMeta2Header(header, filter.CallerMeta{
 			CallerInstance: meta.CallerInstance(),
 			CallerEnv:      meta.CallerEnv(),
 			CallerService:  meta.CallerService(),
 			CallerMethod:   meta.CallerMethod(),
 		})
+		return next(ctx, req)
+	})
+}
 
-		r := req.(*Request)
-		return c._do(ctx, r)
+// 经过 zapp filter 后使用 next 发送一次请求
+func (c *cli) send(ctx context.Context, conn *clientConn, r *Request, next Handler) (*Response, error) {
+	if isWithoutZAppFilter(ctx) {
+		return next(ctx, r)
+	}
+
+	// filter看到的是脱敏后的副本, filter的修改会同步到实际请求和响应, 包含脱敏值的部分除外
+	view := conn.redact.request(r)
+	base := snapshotRequest(view)
+	var sp, spBase *Response
+	// do -> sendWithRetry -> sendBalanced -> send
+	rsp, err := handleClientFilter(ctx, c.Name, r.Method, 4, r.Header, view, func(ctx context.Context, req interface{}) (rsp interface{}, err error) {
+		mergeRequest(r, base, req.(*Request))
+		sp, err = next(ctx, r)
+		if sp == nil {
+			return nil, err
+		}
+		spView := conn.redact.response(sp)
+		spBase = snapshotResponse(spView)
+		return spView, err
 	})
-	if err == nil {
-		sp := rsp.(*Response)
-		err = c.unmarshal(ctx, r, sp)
-		return sp, err
+	if err != nil {
+		return nil, err
 	}
-	return nil, err
+	v, _ := rsp.(*Response)
+	if sp == nil {
+		return v, nil // filter没有发送请求, 直接返回了响应
+	}
+	if v != nil {
+		mergeResponse(sp, spBase, v)
+	}
+	return sp, nil
 }
 
-func (c cli) _do(ctx context.Context, r *Request) (*Response, error) {
+func (c *cli) _do(ctx context.Context, conn *clientConn, r *Request) (*Response, error) {
+	// 流式响应在关闭body时才取消超时和释放并发名额, 其它情况在返回时处理
+	streaming := false
+	cancel := context.CancelFunc(func() {})
 	if r.Timeout > 0 {
-		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
-		defer cancel()
+	}
+	defer func() {
+		if !streaming {
+			cancel()
+		}
+	}()
+
+	var body io.Reader
+	if r.inStream != nil {
+		body = r.inStream
+	} else if r.inMultipart != nil {
+		body = r.inMultipart.open()
+	} else if r.Body != "" {
+		body = strings.NewReader(r.Body) // 每次发送都重新构建, 以便重试时可以重放
+	}
+	var compressed []byte // 压缩后的body, 用于签名
+	if r.inCompress != "" && body != nil {
+		if r.InIsStream {
+			body = compressStream(r.inCompress, body)
+		} else {
+			var err error
+			if compressed, err = compressBytes(r.inCompress, []byte(r.Body)); err != nil {
+				return nil, err
+			}
+			body = bytes.NewReader(compressed)
+		}
 	}
 
 	reqCtx := saveProxy2Ctx(ctx, r.Proxy)
-	httpReq, err := http.NewRequestWithContext(reqCtx, r.Method, r.Path, r.inStream)
+	httpReq, err := http.NewRequestWithContext(reqCtx, r.Method, r.Path, body)
 	if err != nil {
 		return nil, err
 	}
 	if r.Header != nil {
-		httpReq.Header = r.Header
+		// 复制一份, 发送时添加的header不影响调用者的请求
+		httpReq.Header = r.Header.Clone()
+	}
+	if r.autoDecode {
+		httpReq.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	if r.inCompress != "" && body != nil {
+		httpReq.Header.Set("Content-Encoding", r.inCompress)
+	}
+	addParams(httpReq.URL, r.Params)
+
+	client, err := conn.getClient(r)
+	if err != nil {
+		return nil, err
+	}
+	if r.jar != nil {
+		// 复用连接池, 只替换cookie jar, 重定向时也会处理cookie
+		withJar := *client
+		withJar.Jar = r.jar
+		client = &withJar
+	}
+
+	release, err := acquireLimit(ctx, conn, httpReq.URL.Host)
+	if err != nil {
+		return nil, err
 	}
-	if len(r.Params) > 0 {
-		query := httpReq.URL.Query()
-		for k, v := range r.Params {
-			query[k] = append(query[k], v...)
+	defer func() {
+		if !streaming {
+			release()
+		}
+	}()
+
+	if r.signer != nil {
+		var signBody []byte
+		if compressed != nil {
+			signBody = compressed
+		} else if !r.InIsStream {
+			signBody = []byte(r.Body)
+		}
+		if err = r.signer.Sign(httpReq, signBody); err != nil {
+			return nil, fmt.Errorf("请求签名失败: %w", err)
 		}
-		httpReq.URL.RawQuery = query.Encode()
 	}
 
-	var httpRsp *http.Response
-	if r.InsecureSkipVerify {
-		httpRsp, err = rawStdClientInsecureSkipVerify.Do(httpReq)
-	} else {
-		httpRsp, err = rawStdClient.Do(httpReq)
+	brk := getBreaker(breakerKey{client: conn.name, conn: conn.id, host: httpReq.URL.Host}, &conn.conf.Breaker)
+	if brk != nil {
+		if err = brk.allow(); err != nil {
+			return nil, err
+		}
+	}
+	start := time.Now()
+	httpRsp, err := client.Do(httpReq)
+	if brk != nil {
+		if err != nil {
+			brk.done(err, 0)
+		} else {
+			brk.done(nil, httpRsp.StatusCode)
+		}
 	}
 	if err != nil {
+		conn.har.record(start, r, httpReq, nil, nil, err)
 		return nil, err
 	}
 
+	// 自动请求了压缩的响应时解压, 和标准库处理gzip的行为一致
+	if r.autoDecode && canDecode(httpRsp.Header.Get("Content-Encoding")) {
+		httpRsp.Body = newDecodeReadCloser(httpRsp.Body, httpRsp.Header.Get("Content-Encoding"))
+		httpRsp.Header.Del("Content-Encoding")
+		httpRsp.Header.Del("Content-Length")
+		httpRsp.ContentLength = -1
+		httpRsp.Uncompressed = true
+	}
+
 	sp := &Response{}
 	sp.Status = httpRsp.Status
 	sp.StatusCode = httpRsp.StatusCode
 	sp.ContentLength = httpRsp.ContentLength
 	sp.Header = httpRsp.Header
 	sp.Uncompressed = httpRsp.Uncompressed
-	if !r.OutIsStream {
-		body, err := io.ReadAll(httpRsp.Body)
+
+	maxBytes := r.MaxResponseBytes
+	if maxBytes == 0 {
+		maxBytes = conn.conf.MaxResponseBytes
+	}
+	if maxBytes > 0 && httpRsp.ContentLength > maxBytes {
+		_ = httpRsp.Body.Close()
+		return nil, ErrResponseTooLarge
+	}
+
+	if r.outStream != nil && httpRsp.StatusCode >= 200 && httpRsp.StatusCode < 300 {
+		err = handleStream(ctx, r.outStream, newLimitReadCloser(httpRsp.Body, maxBytes))
+		if err != nil {
+			return nil, err
+		}
+	} else if !r.OutIsStream {
+		body, err := readAllLimit(httpRsp.Body, maxBytes)
 		defer httpRsp.Body.Close()
 		if err != nil {
 			return nil, err
 		}
 		sp.Body = string(body)
 	} else {
-		sp.BodyStream = httpRsp.Body
+		streaming = true
+		sp.BodyStream = &cancelReadCloser{
+			ReadCloser: &releaseReadCloser{ReadCloser: newLimitReadCloser(httpRsp.Body, maxBytes), release: release},
+			cancel:     cancel,
+		}
 	}
 
+	conn.har.record(start, r, httpReq, httpRsp, sp, nil)
 	return sp, nil
 }
 
-func (c cli) unmarshal(ctx context.Context, r *Request, sp *Response) error {
+func (c *cli) unmarshal(ctx context.Context, r *Request, sp *Response) error {
 	if r.OutIsStream {
 		return c.unmarshalStream(ctx, r, sp)
 	}
 
-	if r.outJsonPtr != nil {
-		return sonic.UnmarshalString(sp.Body, r.outJsonPtr)
+	if r.outPtr == nil {
+		return nil
 	}
-	if r.outYamlPtr != nil {
-		return yaml.Unmarshal([]byte(sp.Body), r.outYamlPtr)
+	codec, err := c.getOutCodec(r, sp)
+	if err != nil {
+		return err
 	}
-	return nil
+	return codec.Unmarshal([]byte(sp.Body), r.outPtr)
 }
 
-func (c cli) unmarshalStream(ctx context.Context, r *Request, sp *Response) error {
-	if r.outJsonPtr != nil {
-		return sonic.ConfigDefault.NewDecoder(sp.BodyStream).Decode(r.outJsonPtr)
+func (c *cli) unmarshalStream(ctx context.Context, r *Request, sp *Response) error {
+	if r.outPtr == nil {
+		return nil
 	}
-	if r.outYamlPtr != nil {
-		return yaml.NewDecoder(sp.BodyStream).Decode(r.outYamlPtr)
-	}
-	return nil
-}
-
-var StdClient = newStdClient()
-var StdTransport = newStdTransport()
-
-var rawStdDialer = &net.Dialer{
-	Timeout:   30 * time.Second,
-	KeepAlive: 30 * time.Second,
-}
-var rawStdTransport http.RoundTripper = &http.Transport{
-	Proxy:                 proxyResolve,
-	DialContext:           rawStdDialer.DialContext,
-	ForceAttemptHTTP2:     true,
-	MaxIdleConns:          100,
-	IdleConnTimeout:       90 * time.Second,
-	TLSHandshakeTimeout:   10 * time.Second,
-	ExpectContinueTimeout: 1 * time.Second,
-}
-var rawStdTransportInsecureSkipVerify http.RoundTripper = &http.Transport{
-	Proxy:                 proxyResolve,
-	DialContext:           rawStdDialer.DialContext,
-	ForceAttemptHTTP2:     true,
-	MaxIdleConns:          100,
-	IdleConnTimeout:       90 * time.Second,
-	TLSHandshakeTimeout:   10 * time.Second,
-	ExpectContinueTimeout: 1 * time.Second,
-	TLSClientConfig: &tls.Config{
-		InsecureSkipVerify: true, // 跳过tls校验
-		RootCAs:            x509.NewCertPool(),
-	},
-}
-
-var NewTransport = func(name string, insecureSkipVerify bool) http.RoundTripper {
-	return Transport{Name: name, InsecureSkipVerify: insecureSkipVerify}
-}
+	defer sp.BodyStream.Close()
 
-type Transport struct {
-	Name               string
-	InsecureSkipVerify bool
-}
-type roundTripReq struct {
-	Method string
-	Path   string
-	Body   string
-
-	Header Header // 请求head
-	Params Values // 请求参数
-	req    *http.Request
-}
-type roundTripResponse struct {
-	Body       string
-	BodyStream io.ReadCloser // 注意, 读取完毕需要使用者自行调用 Close
-
-	Status        string
-	StatusCode    int
-	ContentLength int64
-	Header        Header
-	Uncompressed  bool
-	rsp           *http.Response
-}
-
-func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
-	if isWithoutZAppFilter(req.Context()) {
-		return rawStdTransport.RoundTrip(req)
-	}
-
-	ctx, chain := filter.GetClientFilter(req.Context(), DefaultComponentType, t.Name, req.Method)
-	meta := filter.GetCallMeta(ctx)
-	meta.AddCallersSkip(1)
-
-	var body []byte
-	var err error
-	if req.Body != nil {
-		body, err = io.ReadAll(req.Body)
-		req.Body.Close()
-		if err != nil {
-			return nil, err
-		}
-		req.Body = io.NopCloser(bytes.NewReader(body))
-	}
-
-	// 附加trace
-	if req.Header == nil {
-		req.Header = make(http.Header)
-	}
-	utils.Trace.SaveToHeaders(ctx, req.Header)
-
-	r := &roundTripReq{
-		Method: req.Method,
-		Path:   req.URL.String(),
-		Body:   string(body),
-		Header: req.Header,
-		Params: req.URL.Query(),
-		req:    req,
+	codec, err := c.getOutCodec(r, sp)
+	if err != nil {
+		return err
 	}
-	rsp, err := chain.Handle(ctx, r, func(ctx context.Context, req interface{}) (rsp interface{}, err error) {
-		// 附加主调信息
-		meta := filter.GetCallMeta(ctx)
-		filter.SaveCallerMeta2Header(r.Header, filter.CallerMeta{
-			CallerInstance: meta.CallerInstance(),
-			CallerEnv:      meta.CallerEnv(),
-			CallerService:  meta.CallerService(),
-			CallerMethod:   meta.CallerMethod(),
-		})
-
-		r := req.(*roundTripReq)
-		var httpRsp *http.Response
-		if t.InsecureSkipVerify {
-			httpRsp, err = rawStdTransportInsecureSkipVerify.RoundTrip(r.req)
-		} else {
-			httpRsp, err = rawStdTransport.RoundTrip(r.req)
-		}
-		if err != nil {
-			return nil, err
-		}
-
-		sp := &roundTripResponse{}
-		sp.Status = httpRsp.Status
-		sp.StatusCode = httpRsp.StatusCode
-		sp.ContentLength = httpRsp.ContentLength
-		sp.Header = httpRsp.Header
-		sp.Uncompressed = httpRsp.Uncompressed
-
-		body, err := io.ReadAll(httpRsp.Body)
-		_ = httpRsp.Body.Close()
-		if err != nil {
-			return nil, err
-		}
-
-		httpRsp.Body = io.NopCloser(bytes.NewReader(body))
+	return decodeStream(codec, sp.BodyStream, r.outPtr)
+}
 
-		sp.Body = string(body)
-		sp.rsp = httpRsp
-		return sp, nil
-	})
-	if err != nil {
-		return nil, err
+// 获取解析响应数据的编解码器
+func (c *cli) getOutCodec(r *Request, sp *Response) (Codec, error) {
+	if r.outCodec == CodecAuto {
+		return getCodecByContentType(sp.Header.Get("Content-Type"))
 	}
-	return rsp.(*roundTripResponse).rsp, nil
+	return getCodec(r.outCodec)
 }
 
+var StdClient = newStdClient()
+var StdTransport = newStdTransport()
+
 func newStdClient() *http.Client {
 	return &http.Client{Transport: StdTransport}
 }
@@ -0,0 +1,238 @@
+package http
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/bytedance/sonic"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// 内置的编解码器名
+const (
+	CodecJson     = "json"
+	CodecYaml     = "yaml"
+	CodecXml      = "xml"
+	CodecProtobuf = "protobuf"
+	CodecMsgpack  = "msgpack"
+	CodecForm     = "form"
+
+	// 仅用于响应, 根据响应的 Content-Type 选择编解码器
+	CodecAuto = "auto"
+)
+
+// 编解码器
+type Codec interface {
+	// 编解码器名, 用于 WithInBody 和 WithOutBody
+	Name() string
+	// 请求时使用的 Content-Type 和 Accept
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// 支持流式解码的编解码器, 响应body是流数据时会优先使用
+type StreamDecoder interface {
+	Decode(r io.Reader, v interface{}) error
+}
+
+var (
+	codecMx           sync.RWMutex
+	codecs            = map[string]Codec{} // 编解码器名 -> 编解码器
+	codecContentTypes = map[string]Codec{} // Content-Type -> 编解码器
+)
+
+func init() {
+	RegisterCodec(jsonCodec{}, "text/json")
+	RegisterCodec(yamlCodec{}, "application/x-yaml", "text/yaml", "text/x-yaml")
+	RegisterCodec(xmlCodec{}, "text/xml")
+	RegisterCodec(protobufCodec{}, "application/protobuf", "application/vnd.google.protobuf")
+	RegisterCodec(msgpackCodec{}, "application/x-msgpack", "application/vnd.msgpack")
+	RegisterCodec(formCodec{})
+}
+
+// 注册编解码器, 同名的编解码器会被替换. contentTypes 为响应自动解码时额外匹配的 Content-Type
+func RegisterCodec(codec Codec, contentTypes ...string) {
+	codecMx.Lock()
+	defer codecMx.Unlock()
+	codecs[codec.Name()] = codec
+	codecContentTypes[codec.ContentType()] = codec
+	for _, ct := range contentTypes {
+		codecContentTypes[ct] = codec
+	}
+}
+
+// 根据编解码器名获取编解码器
+func GetCodec(name string) (Codec, bool) {
+	codecMx.RLock()
+	defer codecMx.RUnlock()
+	codec, ok := codecs[name]
+	return codec, ok
+}
+
+func getCodec(name string) (Codec, error) {
+	codec, ok := GetCodec(name)
+	if !ok {
+		return nil, fmt.Errorf("未注册的编解码器: %s", name)
+	}
+	return codec, nil
+}
+
+// 根据 Content-Type 获取编解码器, 支持 application/xxx+json 这类后缀
+func getCodecByContentType(contentType string) (Codec, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("无法解析响应的 Content-Type %q: %v", contentType, err)
+	}
+
+	codecMx.RLock()
+	defer codecMx.RUnlock()
+	if codec, ok := codecContentTypes[mediaType]; ok {
+		return codec, nil
+	}
+	if i := strings.LastIndexByte(mediaType, '+'); i != -1 {
+		if codec, ok := codecs[mediaType[i+1:]]; ok {
+			return codec, nil
+		}
+	}
+	return nil, fmt.Errorf("没有匹配响应 Content-Type %q 的编解码器", contentType)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string        { return CodecJson }
+func (jsonCodec) ContentType() string { return "application/json" }
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return sonic.ConfigStd.Marshal(v)
+}
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return sonic.Unmarshal(data, v)
+}
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return sonic.ConfigDefault.NewDecoder(r).Decode(v)
+}
+
+type yamlCodec struct{}
+
+func (yamlCodec) Name() string        { return CodecYaml }
+func (yamlCodec) ContentType() string { return "application/yaml" }
+func (yamlCodec) Marshal(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+func (yamlCodec) Unmarshal(data []byte, v interface{}) error {
+	return yaml.Unmarshal(data, v)
+}
+func (yamlCodec) Decode(r io.Reader, v interface{}) error {
+	return yaml.NewDecoder(r).Decode(v)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) Name() string        { return CodecXml }
+func (xmlCodec) ContentType() string { return "application/xml" }
+func (xmlCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+func (xmlCodec) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string        { return CodecProtobuf }
+func (protobufCodec) ContentType() string { return "application/x-protobuf" }
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf编码需要 proto.Message, 实际为 %T", v)
+	}
+	return proto.Marshal(msg)
+}
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf解码需要 proto.Message, 实际为 %T", v)
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string        { return CodecMsgpack }
+func (msgpackCodec) ContentType() string { return "application/msgpack" }
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+func (msgpackCodec) Decode(r io.Reader, v interface{}) error {
+	return msgpack.NewDecoder(r).Decode(v)
+}
+
+// 表单编解码器, 支持 url.Values, map[string]string, map[string][]string
+type formCodec struct{}
+
+func (formCodec) Name() string        { return CodecForm }
+func (formCodec) ContentType() string { return "application/x-www-form-urlencoded" }
+func (formCodec) Marshal(v interface{}) ([]byte, error) {
+	switch form := v.(type) {
+	case url.Values:
+		return []byte(form.Encode()), nil
+	case *url.Values:
+		return []byte(form.Encode()), nil
+	case map[string][]string:
+		return []byte(url.Values(form).Encode()), nil
+	case map[string]string:
+		values := make(url.Values, len(form))
+		for k, v := range form {
+			values.Set(k, v)
+		}
+		return []byte(values.Encode()), nil
+	}
+	return nil, fmt.Errorf("表单编码不支持 %T", v)
+}
+func (formCodec) Unmarshal(data []byte, v interface{}) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	switch form := v.(type) {
+	case *url.Values:
+		*form = values
+		return nil
+	case *map[string][]string:
+		*form = values
+		return nil
+	case *map[string]string:
+		*form = make(map[string]string, len(values))
+		for k := range values {
+			(*form)[k] = values.Get(k)
+		}
+		return nil
+	}
+	return errors.New("表单解码只支持 *url.Values, *map[string][]string, *map[string]string")
+}
+
+// 使用编解码器从流中解码
+func decodeStream(codec Codec, r io.Reader, v interface{}) error {
+	if d, ok := codec.(StreamDecoder); ok {
+		return d.Decode(r, v)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return codec.Unmarshal(data, v)
+}
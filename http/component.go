@@ -0,0 +1,211 @@
+package http
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zly-app/zapp"
+	"github.com/zly-app/zapp/component/conn"
+)
+
+type httpCreator struct {
+	conn *conn.AnyConn[*clientConn]
+}
+
+// 获取客户端名对应的连接信息, 首次获取时会根据配置创建
+func (h *httpCreator) getClientConn(name string) (*clientConn, error) {
+	return h.conn.GetConn(h.makeClientConn, name)
+}
+
+func (h *httpCreator) makeClientConn(name string) (*clientConn, error) {
+	conf := newConfig()
+	err := zapp.App().GetConfig().ParseComponentConfig(DefaultComponentType, name, conf, true)
+	if err == nil {
+		err = conf.Check()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("http客户端<%s>配置错误: %v", name, err)
+	}
+	return newClientConn(name, conf)
+}
+
+func (h *httpCreator) Close() {
+	h.conn.CloseAll()
+}
+
+// 一个客户端名对应的连接信息
+type clientConn struct {
+	name   string
+	id     uint64 // NewClientWithRoundTripper 创建时分配的编号, 根据zapp配置创建的为0
+	conf   *HttpConfig
+	header Header
+	retry  *RetryPolicy
+	tls    TLSConfig
+	status statusPolicy
+	auth   authenticator
+	lb     *balancer
+	cache  *httpCache
+	dns    *dnsCache
+	har    *harRecorder
+	redact *RedactPolicy
+
+	rt http.RoundTripper // 不为nil时所有请求都使用它发送, 忽略tls配置
+
+	mx      sync.RWMutex
+	clients map[TLSConfig]*http.Client // 按tls配置缓存, 相同配置的请求复用连接
+}
+
+func newClientConn(name string, conf *HttpConfig) (*clientConn, error) {
+	header := make(Header, len(conf.Header))
+	for k, v := range conf.Header {
+		header.Set(k, v)
+	}
+
+	minVersion, err := parseTLSVersion(conf.MinTLSVersion)
+	if err != nil {
+		return nil, err
+	}
+	tlsConf := TLSConfig{
+		InsecureSkipVerify: conf.InsecureSkipVerify,
+		CertFile:           conf.CertFile,
+		KeyFile:            conf.KeyFile,
+		CertPEM:            conf.Cert,
+		KeyPEM:             conf.Key,
+		CAFile:             conf.CAFile,
+		CAPEM:              conf.CA,
+		ServerName:         conf.ServerName,
+		MinVersion:         minVersion,
+	}
+	// 提前检查证书, 以便配置错误时尽早报错
+	if _, err = tlsConf.build(); err != nil {
+		return nil, err
+	}
+
+	c := &clientConn{
+		name:    name,
+		conf:    conf,
+		header:  header,
+		retry:   newRetryPolicy(&conf.Retry),
+		tls:     tlsConf,
+		status:  statusPolicy{enable: conf.CheckStatus, codes: conf.ExpectStatus},
+		clients: make(map[TLSConfig]*http.Client),
+		lb:      newBalancer(conf),
+		dns:     newDNSCache(name, &conf.DNS),
+		har:     newHarRecorder(name, &conf.Har),
+	}
+	c.auth = newAuthenticator(&conf.Auth, c)
+	if c.redact, err = NewRedactPolicy(conf.Redact); err != nil {
+		return nil, err
+	}
+	if c.cache, err = newHttpCache(name, &conf.Cache); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// 获取用于发送请求的 http.Client, 请求的tls配置会覆盖客户端的tls配置
+func (c *clientConn) getClient(r *Request) (*http.Client, error) {
+	if c.rt != nil {
+		return &http.Client{Transport: c.rt}, nil
+	}
+
+	tlsConf := c.tls.merge(r.tls)
+	if r.InsecureSkipVerify {
+		tlsConf.InsecureSkipVerify = true
+	}
+
+	c.mx.RLock()
+	client, ok := c.clients[tlsConf]
+	c.mx.RUnlock()
+	if ok {
+		return client, nil
+	}
+
+	conf, err := tlsConf.build()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if client, ok = c.clients[tlsConf]; ok {
+		return client, nil
+	}
+	client = &http.Client{Transport: makeTransport(c.conf, conf, c.dns)}
+	c.clients[tlsConf] = client
+	return client, nil
+}
+
+// 将客户端的默认配置应用到请求上
+func (c *clientConn) applyDefault(r *Request) {
+	if c.lb != nil && !strings.Contains(r.Path, "://") {
+		r.relPath = r.Path
+	} else {
+		r.Path = joinBaseUrl(c.conf.BaseUrl, r.Path)
+	}
+
+	if r.Header == nil {
+		r.Header = make(Header, len(c.header))
+	}
+	for k, v := range c.header {
+		if _, ok := r.Header[k]; !ok {
+			r.Header[k] = append([]string(nil), v...)
+		}
+	}
+
+	if r.Timeout == 0 && c.conf.Timeout > 0 {
+		r.Timeout = time.Duration(c.conf.Timeout) * time.Millisecond
+	}
+	if r.Proxy == "" {
+		r.Proxy = c.conf.Proxy
+	}
+}
+
+func (c *clientConn) Close() {
+	removeBreakers(c.name, c.id)
+	removeLimiters(c.name, c.id)
+	c.har.Close()
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	for _, client := range c.clients {
+		client.CloseIdleConnections()
+	}
+}
+
+func makeTransport(conf *HttpConfig, tlsConf *tls.Config, dns *dnsCache) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(conf.DialTimeout) * time.Millisecond,
+		KeepAlive: time.Duration(conf.KeepAlive) * time.Millisecond,
+	}
+	return &http.Transport{
+		Proxy:                 proxyResolve,
+		DialContext:           dns.dialContext(dialer),
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          conf.MaxIdleConns,
+		MaxIdleConnsPerHost:   conf.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       conf.MaxConnsPerHost,
+		IdleConnTimeout:       time.Duration(conf.IdleConnTimeout) * time.Millisecond,
+		TLSHandshakeTimeout:   time.Duration(conf.TLSHandshakeTimeout) * time.Millisecond,
+		ExpectContinueTimeout: time.Duration(conf.ExpectContinueTimeout) * time.Millisecond,
+		DisableKeepAlives:     conf.DisableKeepAlives,
+		DisableCompression:    conf.DisableCompression,
+		TLSClientConfig:       tlsConf,
+	}
+}
+
+// 拼接基础地址, path是完整url时直接返回path
+func joinBaseUrl(baseUrl, path string) string {
+	if baseUrl == "" || strings.Contains(path, "://") {
+		return path
+	}
+	if path == "" {
+		return baseUrl
+	}
+	return strings.TrimRight(baseUrl, "/") + "/" + strings.TrimLeft(path, "/")
+}
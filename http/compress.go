@@ -0,0 +1,178 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// 压缩编码
+const (
+	EncodingGzip   = "gzip"
+	EncodingZstd   = "zstd"
+	EncodingBrotli = "br"
+)
+
+// 自动解压响应时发送的 Accept-Encoding
+const acceptEncoding = "gzip, zstd, br"
+
+// 使用指定的编码压缩请求body, 会设置 Content-Encoding, 支持 gzip, zstd, br.
+// Body, WithInJson 等数据在发送前压缩, 可以重试. WithInBodyStream, WithInMultipart 边读边压缩
+func WithInCompress(encoding string) Option {
+	return func(r *Request) {
+		r.inCompress = encoding
+	}
+}
+
+func checkEncoding(encoding string) error {
+	switch encoding {
+	case EncodingGzip, EncodingZstd, EncodingBrotli:
+		return nil
+	}
+	return fmt.Errorf("不支持的压缩编码: %s", encoding)
+}
+
+func newCompressWriter(encoding string, w io.Writer) (io.WriteCloser, error) {
+	switch encoding {
+	case EncodingGzip:
+		return gzip.NewWriter(w), nil
+	case EncodingZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	case EncodingBrotli:
+		return brotli.NewWriter(w), nil
+	}
+	return nil, checkEncoding(encoding)
+}
+
+// 压缩数据
+func compressBytes(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := newCompressWriter(encoding, &buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 边读边压缩, 首次读取时才开始压缩, 关闭后压缩协程会退出
+type compressReader struct {
+	encoding string
+	src      io.Reader
+	once     sync.Once
+	pr       *io.PipeReader
+	pw       *io.PipeWriter
+}
+
+func compressStream(encoding string, src io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	return &compressReader{encoding: encoding, src: src, pr: pr, pw: pw}
+}
+
+func (c *compressReader) Read(p []byte) (int, error) {
+	c.once.Do(func() { go c.compress() })
+	return c.pr.Read(p)
+}
+
+// 同时关闭src, 和未压缩时 http.Request 关闭body一致, 也避免src为multipart时写入协程一直阻塞
+func (c *compressReader) Close() error {
+	err := c.pr.Close()
+	if closer, ok := c.src.(io.Closer); ok {
+		if e := closer.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (c *compressReader) compress() {
+	w, err := newCompressWriter(c.encoding, c.pw)
+	if err != nil {
+		_ = c.pw.CloseWithError(err)
+		return
+	}
+	if _, err = io.Copy(w, c.src); err != nil {
+		_ = c.pw.CloseWithError(err)
+		return
+	}
+	_ = c.pw.CloseWithError(w.Close())
+}
+
+// 是否支持解压这个 Content-Encoding
+func canDecode(contentEncoding string) bool {
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case EncodingGzip, "x-gzip", EncodingZstd, EncodingBrotli:
+		return true
+	}
+	return false
+}
+
+// 解压响应body, 首次读取时才创建解压器, 以便空body不会报错
+type decodeReadCloser struct {
+	body     io.ReadCloser
+	encoding string
+	r        io.Reader
+	closer   func()
+	err      error
+}
+
+func newDecodeReadCloser(body io.ReadCloser, contentEncoding string) io.ReadCloser {
+	return &decodeReadCloser{body: body, encoding: strings.ToLower(strings.TrimSpace(contentEncoding))}
+}
+
+func (d *decodeReadCloser) init() error {
+	switch d.encoding {
+	case EncodingGzip, "x-gzip":
+		zr, err := gzip.NewReader(d.body)
+		if err != nil {
+			return err
+		}
+		d.r = zr
+	case EncodingZstd:
+		zr, err := zstd.NewReader(d.body, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return err
+		}
+		d.r, d.closer = zr, zr.Close
+	case EncodingBrotli:
+		d.r = brotli.NewReader(d.body)
+	default:
+		return fmt.Errorf("不支持的压缩编码: %s", d.encoding)
+	}
+	return nil
+}
+
+func (d *decodeReadCloser) Read(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	if d.r == nil {
+		if err := d.init(); err != nil {
+			d.err = err
+			return 0, err
+		}
+	}
+	n, err := d.r.Read(p)
+	if err != nil {
+		d.err = err
+	}
+	return n, err
+}
+
+func (d *decodeReadCloser) Close() error {
+	if d.closer != nil {
+		d.closer()
+	}
+	return d.body.Close()
+}
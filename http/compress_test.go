@@ -0,0 +1,40 @@
+package http
+
+import (
+	"compress/gzip"
+	"context"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 同一个请求多次发送时都会自动请求压缩并解压
+func TestAutoDecodeReusedRequest(t *testing.T) {
+	var encodings []string
+	srv := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		encodings = append(encodings, r.Header.Get("Accept-Encoding"))
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		_, _ = zw.Write([]byte("hello"))
+		_ = zw.Close()
+	}))
+	defer srv.Close()
+
+	c := newRetryTestClient(t)
+	req := NewRequest(stdhttp.MethodGet, srv.URL, "")
+	for i := 0; i < 2; i++ {
+		sp, err := c.Do(WithoutZAppFilter(context.Background()), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sp.Body != "hello" || !sp.Uncompressed {
+			t.Errorf("send %d: body = %q, Uncompressed = %v, want decoded", i+1, sp.Body, sp.Uncompressed)
+		}
+		if encodings[i] != acceptEncoding {
+			t.Errorf("send %d: Accept-Encoding = %q, want %q", i+1, encodings[i], acceptEncoding)
+		}
+	}
+	if v := req.Header.Get("Accept-Encoding"); v != "" {
+		t.Errorf("request Accept-Encoding = %q, want it not written to the caller's header", v)
+	}
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// 将请求转为curl命令, 用于复现请求. 包含方法, 带参数的url, header和body, 发送后调用时包含客户端默认header和trace等自动添加的header.
+// HEAD 请求使用 -I, 自动解压响应时使用 --compressed 代替 Accept-Encoding, 压缩的请求body以原始数据导出且不包含 Content-Encoding.
+// 流数据和multipart的body无法导出, 会在命令末尾以注释说明
+func (r *Request) ToCurl() string {
+	body, bodyOk := r.curlBody()
+
+	var sb strings.Builder
+	sb.WriteString("curl")
+	if r.Method == "HEAD" {
+		// -X HEAD 会让curl等待不存在的响应body
+		sb.WriteString(" -I")
+	} else if r.Method != "" && (r.Method != "GET" || body != "") {
+		sb.WriteString(" -X ")
+		sb.WriteString(shellQuote(r.Method))
+	}
+	if r.InsecureSkipVerify {
+		sb.WriteString(" -k")
+	}
+	if r.Proxy != "" {
+		sb.WriteString(" --proxy ")
+		sb.WriteString(shellQuote(r.Proxy))
+	}
+	if r.autoDecode {
+		sb.WriteString(" --compressed")
+	}
+	sb.WriteString(" ")
+	sb.WriteString(shellQuote(r.curlUrl()))
+
+	keys := make([]string, 0, len(r.Header))
+	for k := range r.Header {
+		// body压缩后才需要
+		if r.inCompress != "" && k == "Content-Encoding" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range r.Header[k] {
+			sb.WriteString(" -H ")
+			sb.WriteString(shellQuote(k + ": " + v))
+		}
+	}
+	if r.inPtr != nil && r.Header.Get("Content-Type") == "" {
+		if codec, err := getCodec(r.inCodec); err == nil {
+			sb.WriteString(" -H ")
+			sb.WriteString(shellQuote("Content-Type: " + codec.ContentType()))
+		}
+	}
+
+	if body != "" {
+		sb.WriteString(" --data-binary ")
+		sb.WriteString(shellQuote(body))
+	}
+	if !bodyOk {
+		sb.WriteString(" # body is a stream and is not included")
+	}
+	return sb.String()
+}
+
+// 获取请求body, 无法导出时返回false
+func (r *Request) curlBody() (string, bool) {
+	switch {
+	case r.Body != "":
+		return r.Body, true
+	case r.inPtr != nil:
+		codec, err := getCodec(r.inCodec)
+		if err != nil {
+			return "", false
+		}
+		body, err := codec.Marshal(r.inPtr)
+		if err != nil {
+			return "", false
+		}
+		return string(body), true
+	case r.inStream != nil, r.inMultipart != nil:
+		return "", false
+	}
+	return "", true
+}
+
+// 获取附加了请求参数的url, 和发送时的拼接方式一致
+func (r *Request) curlUrl() string {
+	if len(r.Params) == 0 {
+		return r.Path
+	}
+	u, err := url.Parse(r.Path)
+	if err != nil {
+		return r.Path
+	}
+	addParams(u, r.Params)
+	return u.String()
+}
+
+// 使用单引号转义shell参数
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
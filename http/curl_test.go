@@ -0,0 +1,71 @@
+package http
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestToCurl(t *testing.T) {
+	tests := []struct {
+		name string
+		req  func() *Request
+		want string
+	}{
+		{
+			name: "get",
+			req:  func() *Request { return NewRequest("GET", "https://example.com/a", "") },
+			want: `curl 'https://example.com/a'`,
+		},
+		{
+			name: "head",
+			req:  func() *Request { return NewRequest("HEAD", "https://example.com/a", "") },
+			want: `curl -I 'https://example.com/a'`,
+		},
+		{
+			name: "post with header and params",
+			req: func() *Request {
+				r := NewRequest("POST", "https://example.com/a?x=1", `{"a":"it's"}`)
+				r.Header = Header{"X-App": {"demo"}}
+				r.Params = url.Values{"y": {"2"}}
+				return r
+			},
+			want: `curl -X 'POST' 'https://example.com/a?x=1&y=2' -H 'X-App: demo' --data-binary '{"a":"it'\''s"}'`,
+		},
+		{
+			name: "json body",
+			req: func() *Request {
+				r := NewRequest("PUT", "https://example.com/a", "")
+				r.applyOptions(WithInJson(map[string]int{"a": 1}))
+				return r
+			},
+			want: `curl -X 'PUT' 'https://example.com/a' -H 'Content-Type: application/json' --data-binary '{"a":1}'`,
+		},
+		{
+			name: "compressed, insecure and proxy",
+			req: func() *Request {
+				r := NewRequest("GET", "https://example.com/a", "")
+				r.autoDecode = true
+				r.applyOptions(WithInsecureSkipVerify(), WithProxy("http://127.0.0.1:8080"))
+				return r
+			},
+			want: `curl -k --proxy 'http://127.0.0.1:8080' --compressed 'https://example.com/a'`,
+		},
+		{
+			name: "stream body",
+			req: func() *Request {
+				r := NewRequest("POST", "https://example.com/a", "")
+				r.applyOptions(WithInBodyStream(strings.NewReader("data")))
+				return r
+			},
+			want: `curl -X 'POST' 'https://example.com/a' # body is a stream and is not included`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.req().ToCurl(); got != tt.want {
+				t.Errorf("ToCurl() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
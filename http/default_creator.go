@@ -0,0 +1,20 @@
+package http
+
+import (
+	"github.com/zly-app/zapp"
+	"github.com/zly-app/zapp/component/conn"
+	"github.com/zly-app/zapp/core"
+	"github.com/zly-app/zapp/handler"
+)
+
+var defCreator = &httpCreator{
+	conn: conn.NewAnyConn[*clientConn](func(name string, conn *clientConn) {
+		conn.Close()
+	}),
+}
+
+func init() {
+	zapp.AddHandler(zapp.AfterCloseComponent, func(_ core.IApp, _ handler.HandlerType) {
+		defCreator.Close()
+	})
+}
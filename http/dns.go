@@ -0,0 +1,215 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dns解析器, *net.Resolver 实现了这个接口
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) (addrs []string, err error)
+}
+
+// 使用 Transport(ReplaceStd, NewTransport) 的解析器名
+const StdResolverName = "std"
+
+// 为客户端设置的自定义解析器
+var resolvers sync.Map // 客户端名 -> Resolver
+
+// 为客户端设置自定义dns解析器, name 为客户端名, 为 StdResolverName 时设置 Transport 使用的解析器. 静态host的优先级高于解析器
+func SetResolver(name string, r Resolver) {
+	resolvers.Store(name, r)
+}
+
+// Transport 使用的dns缓存
+var stdDNS = newDNSCache(StdResolverName, &DNSConfig{})
+
+// 设置 Transport(ReplaceStd, NewTransport) 的dns缓存和静态host
+func SetStdDNSConfig(conf DNSConfig) {
+	stdDNS.reset(&conf)
+}
+
+type dnsEntry struct {
+	addrs    []string
+	expireAt time.Time
+}
+
+// dns缓存, 静态host优先, 然后是缓存, 最后使用解析器解析
+type dnsCache struct {
+	name string
+
+	mx      sync.RWMutex
+	ttl     time.Duration
+	hosts   map[string][]string
+	entries map[string]*dnsEntry
+}
+
+func newDNSCache(name string, conf *DNSConfig) *dnsCache {
+	d := &dnsCache{name: name}
+	d.reset(conf)
+	return d
+}
+
+func (d *dnsCache) reset(conf *DNSConfig) {
+	hosts := make(map[string][]string, len(conf.Hosts))
+	for host, addrs := range conf.Hosts {
+		hosts[strings.ToLower(host)] = addrs
+	}
+
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	d.ttl = time.Duration(conf.CacheTTL) * time.Millisecond
+	d.hosts = hosts
+	d.entries = make(map[string]*dnsEntry)
+}
+
+func (d *dnsCache) resolver() Resolver {
+	if v, ok := resolvers.Load(d.name); ok {
+		return v.(Resolver)
+	}
+	return net.DefaultResolver
+}
+
+// 是否配置了静态host, dns缓存或自定义解析器, 没有配置时直接使用 net.Dialer 拨号
+func (d *dnsCache) enabled() bool {
+	d.mx.RLock()
+	configured := len(d.hosts) > 0 || d.ttl > 0
+	d.mx.RUnlock()
+	if configured {
+		return true
+	}
+	_, ok := resolvers.Load(d.name)
+	return ok
+}
+
+// 解析host, 解析失败时如果有过期的缓存会使用它
+func (d *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	host = strings.ToLower(host)
+	now := time.Now()
+
+	d.mx.RLock()
+	addrs, ok := d.hosts[host]
+	entry := d.entries[host]
+	ttl := d.ttl
+	d.mx.RUnlock()
+	if ok {
+		return addrs, nil
+	}
+	if entry != nil && now.Before(entry.expireAt) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := d.resolver().LookupHost(ctx, host)
+	if err == nil && len(addrs) == 0 {
+		err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	if err != nil {
+		if entry != nil {
+			return entry.addrs, nil
+		}
+		return nil, err
+	}
+
+	if ttl > 0 {
+		d.mx.Lock()
+		d.evict(now)
+		d.entries[host] = &dnsEntry{addrs: addrs, expireAt: now.Add(ttl)}
+		d.mx.Unlock()
+	}
+	return addrs, nil
+}
+
+// 删除过期超过一个ttl的缓存, 过期后保留一个ttl用于解析失败时使用. 需要持有写锁
+func (d *dnsCache) evict(now time.Time) {
+	for host, entry := range d.entries {
+		if now.Sub(entry.expireAt) > d.ttl {
+			delete(d.entries, host)
+		}
+	}
+}
+
+// 返回使用dns缓存的拨号函数, 依次尝试解析出的每个地址, 和 net.Dialer 一样将超时时间分配给各个地址.
+// 没有配置静态host, dns缓存和自定义解析器时直接使用 dialer 拨号
+func (d *dnsCache) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if !d.enabled() {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		addrs, err := d.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		addrs = filterAddrs(network, addrs)
+		if len(addrs) == 0 {
+			return nil, &net.AddrError{Err: "no suitable address found", Addr: host}
+		}
+
+		deadline, hasDeadline := ctx.Deadline()
+		if dialer.Timeout > 0 {
+			if t := time.Now().Add(dialer.Timeout); !hasDeadline || t.Before(deadline) {
+				deadline, hasDeadline = t, true
+			}
+		}
+
+		var errs []error
+		for i, ip := range addrs {
+			dialCtx, cancel := ctx, context.CancelFunc(func() {})
+			if hasDeadline {
+				dialCtx, cancel = context.WithDeadline(ctx, partialDeadline(time.Now(), deadline, len(addrs)-i))
+			}
+			conn, err := dialer.DialContext(dialCtx, network, net.JoinHostPort(ip, port))
+			cancel()
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, errors.Join(errs...)
+	}
+}
+
+// 每个地址最少的拨号时间, 和 net.Dialer 一致
+const minDialTimeout = 2 * time.Second
+
+// 将剩余的时间平均分配给剩余的地址, 每个地址最少 minDialTimeout
+func partialDeadline(now, deadline time.Time, addrsRemaining int) time.Time {
+	remaining := deadline.Sub(now)
+	timeout := remaining / time.Duration(addrsRemaining)
+	if timeout < minDialTimeout {
+		timeout = min(remaining, minDialTimeout)
+	}
+	return now.Add(timeout)
+}
+
+// 按网络类型过滤地址, tcp4 只保留ipv4, tcp6 只保留ipv6
+func filterAddrs(network string, addrs []string) []string {
+	if !strings.HasSuffix(network, "4") && !strings.HasSuffix(network, "6") {
+		return addrs
+	}
+	ret := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			continue
+		}
+		if is4 := ip.To4() != nil; is4 == strings.HasSuffix(network, "4") {
+			ret = append(ret, addr)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,271 @@
+package http
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 下载文件的校验和不匹配时返回这个错误
+var ErrChecksumMismatch = errors.New("http: download checksum mismatch")
+
+// 下载中的临时文件后缀, 下载完成并校验通过后才会重命名为目标文件
+const downloadTempSuffix = ".download"
+
+// 保存临时文件对应的 ETag 或 Last-Modified 的文件后缀, 续传时作为 If-Range 发送
+const downloadValidatorSuffix = ".validator"
+
+type downloadConf struct {
+	progress     func(written, total int64)
+	checksumAlgo string
+	checksum     string
+}
+
+func newChecksumHash(algo string) (hash.Hash, error) {
+	switch strings.ToLower(algo) {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("不支持的校验和算法: %s", algo)
+}
+
+// 下载文件到 dstPath. 数据先写入 dstPath.download, 如果它已存在会使用 Range 和 If-Range 请求续传,
+// 服务器上的文件已变化或不支持续传时重新下载. 下载完成并校验通过后重命名为 dstPath
+func (c *cli) Download(ctx context.Context, url, dstPath string, opts ...Option) (*Response, error) {
+	tmpPath := dstPath + downloadTempSuffix
+	validatorPath := tmpPath + downloadValidatorSuffix
+	// 续传失败时删除临时文件重新下载, 只重试一次
+	for restarted := false; ; restarted = true {
+		req := NewRequest(http.MethodGet, url, "")
+		req.applyOptions(opts...)
+		req.OutIsStream = true
+
+		var h hash.Hash
+		if req.download.checksumAlgo != "" {
+			var err error
+			if h, err = newChecksumHash(req.download.checksumAlgo); err != nil {
+				return nil, err
+			}
+		}
+
+		offset := loadDownloadOffset(tmpPath, validatorPath)
+		if offset > 0 {
+			validator, _ := os.ReadFile(validatorPath)
+			if req.Header == nil {
+				req.Header = make(Header)
+			}
+			req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+			req.Header.Set("If-Range", string(validator))
+		}
+
+		sp, err := c.do(ctx, req)
+		if offset > 0 && sp != nil && sp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			if sp.BodyStream != nil {
+				_ = sp.BodyStream.Close()
+			}
+			// 临时文件已经下载完整时直接完成, 否则删除后重新下载
+			if size, ok := contentRangeSize(sp.Header.Get("Content-Range")); ok && size == offset {
+				if req.download.progress != nil {
+					req.download.progress(offset, offset)
+				}
+				return sp, finishDownload(tmpPath, dstPath, h, req.download.checksum)
+			}
+			if restarted {
+				return sp, fmt.Errorf("续传下载失败: %s", sp.Status)
+			}
+			if err = removeDownloadTemp(tmpPath); err != nil {
+				return sp, err
+			}
+			continue
+		}
+		if err != nil {
+			return sp, err
+		}
+
+		flag := os.O_CREATE | os.O_WRONLY
+		switch {
+		case sp.StatusCode == http.StatusPartialContent && offset > 0:
+			// 返回的范围必须从临时文件末尾开始
+			if start, ok := contentRangeStart(sp.Header.Get("Content-Range")); !ok || start != offset {
+				_ = sp.BodyStream.Close()
+				if restarted {
+					return sp, fmt.Errorf("续传下载的 Content-Range 不匹配: %q, 期望从 %d 开始", sp.Header.Get("Content-Range"), offset)
+				}
+				if err = removeDownloadTemp(tmpPath); err != nil {
+					return sp, err
+				}
+				continue
+			}
+			flag |= os.O_APPEND
+		case sp.StatusCode >= 200 && sp.StatusCode < 300:
+			// 服务器不支持续传或文件已变化时重新下载
+			flag |= os.O_TRUNC
+			offset = 0
+			if err = saveDownloadValidator(validatorPath, sp.Header); err != nil {
+				_ = sp.BodyStream.Close()
+				return sp, err
+			}
+		default:
+			body, _ := readAllLimit(sp.BodyStream, statusErrorBodyLimit)
+			_ = sp.BodyStream.Close()
+			return sp, &StatusError{
+				StatusCode: sp.StatusCode,
+				Status:     sp.Status,
+				Header:     sp.Header,
+				Body:       string(body),
+			}
+		}
+
+		err = writeDownload(tmpPath, flag, offset, sp, req.download.progress)
+		if err != nil {
+			return sp, err
+		}
+		return sp, finishDownload(tmpPath, dstPath, h, req.download.checksum)
+	}
+}
+
+// 获取可以续传的临时文件大小. 没有保存 If-Range 校验值时无法确认服务器上的文件没有变化, 删除临时文件重新下载
+func loadDownloadOffset(tmpPath, validatorPath string) int64 {
+	info, err := os.Stat(tmpPath)
+	if err != nil || info.Size() == 0 {
+		return 0
+	}
+	if validator, err := os.ReadFile(validatorPath); err == nil && len(validator) > 0 {
+		return info.Size()
+	}
+	_ = removeDownloadTemp(tmpPath)
+	return 0
+}
+
+// 保存续传时使用的校验值, 优先使用强 ETag, 弱 ETag 不能用于 If-Range
+func saveDownloadValidator(validatorPath string, header Header) error {
+	validator := header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = header.Get("Last-Modified")
+	}
+	if validator == "" {
+		if err := os.Remove(validatorPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(validatorPath, []byte(validator), 0o644)
+}
+
+// 删除临时文件和它的校验值
+func removeDownloadTemp(tmpPath string) error {
+	for _, path := range []string{tmpPath, tmpPath + downloadValidatorSuffix} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// 将响应body写入临时文件, 写入后关闭body
+func writeDownload(tmpPath string, flag int, offset int64, sp *Response, progress func(written, total int64)) error {
+	defer sp.BodyStream.Close()
+	f, err := os.OpenFile(tmpPath, flag, 0o644)
+	if err != nil {
+		return err
+	}
+
+	total := int64(-1)
+	if sp.ContentLength >= 0 {
+		total = offset + sp.ContentLength
+	}
+	w := &progressWriter{w: f, written: offset, total: total, fn: progress}
+	_, err = io.Copy(w, sp.BodyStream)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// 校验临时文件后重命名为目标文件, 校验失败时删除临时文件
+func finishDownload(tmpPath, dstPath string, h hash.Hash, checksum string) error {
+	if h != nil {
+		if err := verifyChecksum(tmpPath, h, checksum); err != nil {
+			_ = removeDownloadTemp(tmpPath)
+			return err
+		}
+	}
+	if err := os.Rename(tmpPath, dstPath); err != nil {
+		return err
+	}
+	_ = os.Remove(tmpPath + downloadValidatorSuffix)
+	return nil
+}
+
+// 解析 Content-Range 中的起始位置, 如 bytes 100-199/1234
+func contentRangeStart(contentRange string) (int64, bool) {
+	unit, rng, ok := strings.Cut(strings.TrimSpace(contentRange), " ")
+	if !ok || unit != "bytes" {
+		return 0, false
+	}
+	start, _, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	return n, err == nil
+}
+
+// 解析 Content-Range 中的完整大小, 如 bytes */1234, bytes 0-99/1234
+func contentRangeSize(contentRange string) (int64, bool) {
+	_, size, ok := strings.Cut(contentRange, "/")
+	if !ok || size == "*" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+	return n, err == nil
+}
+
+func verifyChecksum(path string, h hash.Hash, expect string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = io.Copy(h, f); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, expect) {
+		return fmt.Errorf("%w: expect %s, got %s", ErrChecksumMismatch, expect, sum)
+	}
+	return nil
+}
+
+// 写入时回调进度
+type progressWriter struct {
+	w       io.Writer
+	written int64
+	total   int64
+	fn      func(written, total int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	if p.fn != nil {
+		p.fn(p.written, p.total)
+	}
+	return n, err
+}
@@ -0,0 +1,104 @@
+package http
+
+import (
+	"context"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloadResume(t *testing.T) {
+	const content = "0123456789abcdefghij"
+	tests := []struct {
+		name        string
+		tmp         string // 已下载的临时文件内容
+		validator   string // 已保存的 If-Range 校验值
+		etag        string // 服务器返回的 ETag
+		ignoreRange bool   // 服务器不支持 Range
+		badRange    bool   // 服务器返回的 Content-Range 与请求不一致
+		wantRange   string // 服务器收到的第一个 Range
+		wantHits    int
+	}{
+		{name: "no temp file", etag: `"v1"`, wantHits: 1},
+		{name: "resume", tmp: content[:5], validator: `"v1"`, etag: `"v1"`, wantRange: "bytes=5-", wantHits: 1},
+		{name: "file changed", tmp: "xxxxx", validator: `"v0"`, etag: `"v1"`, wantRange: "bytes=5-", wantHits: 1},
+		{name: "no validator", tmp: "xxxxx", etag: `"v1"`, wantHits: 1},
+		{name: "server ignores range", tmp: "xxxxx", validator: `"v1"`, etag: `"v1"`, ignoreRange: true, wantRange: "bytes=5-", wantHits: 1},
+		{name: "content range mismatch", tmp: "xxxxx", validator: `"v1"`, etag: `"v1"`, badRange: true, wantRange: "bytes=5-", wantHits: 2},
+		{name: "already complete", tmp: content, validator: `"v1"`, etag: `"v1"`, wantRange: "bytes=20-", wantHits: 1},
+		{name: "complete but larger", tmp: content + "xx", validator: `"v1"`, etag: `"v1"`, wantRange: "bytes=22-", wantHits: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ranges []string
+			srv := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+				ranges = append(ranges, r.Header.Get("Range"))
+				w.Header().Set("ETag", tt.etag)
+				switch {
+				case tt.ignoreRange:
+					_, _ = w.Write([]byte(content))
+				case tt.badRange && r.Header.Get("Range") != "":
+					w.Header().Set("Content-Range", "bytes 0-19/20")
+					w.WriteHeader(stdhttp.StatusPartialContent)
+					_, _ = w.Write([]byte(content))
+				default:
+					stdhttp.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+				}
+			}))
+			defer srv.Close()
+
+			dst := filepath.Join(t.TempDir(), "a.txt")
+			tmp := dst + downloadTempSuffix
+			if tt.tmp != "" {
+				if err := os.WriteFile(tmp, []byte(tt.tmp), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.validator != "" {
+				if err := os.WriteFile(tmp+downloadValidatorSuffix, []byte(tt.validator), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c := newRetryTestClient(t)
+			if _, err := c.Download(WithoutZAppFilter(context.Background()), srv.URL, dst); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := os.ReadFile(dst); string(got) != content {
+				t.Errorf("file = %q, want %q", got, content)
+			}
+			if len(ranges) != tt.wantHits || ranges[0] != tt.wantRange {
+				t.Errorf("ranges = %q, want %d requests starting with %q", ranges, tt.wantHits, tt.wantRange)
+			}
+			for _, path := range []string{tmp, tmp + downloadValidatorSuffix} {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("%s is not removed", filepath.Base(path))
+				}
+			}
+		})
+	}
+}
+
+func TestContentRangeStart(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+		ok    bool
+	}{
+		{value: "bytes 100-199/1234", want: 100, ok: true},
+		{value: "bytes 0-0/*", want: 0, ok: true},
+		{value: "bytes */1234", ok: false},
+		{value: "items 1-2/3", ok: false},
+		{value: "", ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := contentRangeStart(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("contentRangeStart(%q) = %d, %v, want %d, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
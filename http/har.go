@@ -0,0 +1,313 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+var (
+	harHead = []byte(`{"log":{"version":"1.2","creator":{"name":"github.com/zly-app/component/http","version":"1.0"},"entries":[` + "\n")
+	harTail = []byte("\n]}}\n")
+)
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	Url         string         `json:"url"`
+	HttpVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HttpVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Error           string      `json:"_error,omitempty"` // 请求失败的原因
+}
+
+// har记录器, 将实际发送的请求和响应追加到har文件中, 文件始终是完整的har, 超过大小后轮转. 写入失败时忽略
+type harRecorder struct {
+	conf *HarConfig
+
+	mx   sync.Mutex
+	f    *os.File
+	size int64 // 当前文件大小, 包含结尾
+}
+
+func newHarRecorder(name string, conf *HarConfig) *harRecorder {
+	if !conf.Enable {
+		return nil
+	}
+	if conf.Path == "" {
+		conf.Path = filepath.Join("har", name+".har")
+	}
+	return &harRecorder{conf: conf}
+}
+
+// 记录一次请求, rsp 为nil表示请求失败
+func (h *harRecorder) record(start time.Time, r *Request, req *http.Request, rsp *http.Response, sp *Response, err error) {
+	if h == nil {
+		return
+	}
+	cost := float64(time.Since(start).Microseconds()) / 1000
+
+	entry := &harEntry{
+		StartedDateTime: start.Format(time.RFC3339Nano),
+		Time:            cost,
+		Request: harRequest{
+			Method:      req.Method,
+			Url:         req.URL.String(),
+			HttpVersion: req.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     h.headers(req.Header),
+			QueryString: harValues(req.URL.Query()),
+			HeadersSize: -1,
+			BodySize:    req.ContentLength,
+		},
+		Response: harResponse{
+			Cookies:     []harNameValue{},
+			Headers:     []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Timings: harTimings{Send: 0, Wait: cost, Receive: 0},
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		post := &harPostData{MimeType: req.Header.Get("Content-Type")}
+		if r.InIsStream {
+			post.Comment = "stream body is not recorded"
+		} else {
+			post.Text, post.Comment = h.body(r.Body)
+		}
+		entry.Request.PostData = post
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if rsp != nil {
+		entry.Response.Status = rsp.StatusCode
+		entry.Response.StatusText = http.StatusText(rsp.StatusCode)
+		entry.Response.HttpVersion = rsp.Proto
+		entry.Response.Headers = h.headers(rsp.Header)
+		entry.Response.RedirectURL = rsp.Header.Get("Location")
+		entry.Response.BodySize = rsp.ContentLength
+		mimeType := rsp.Header.Get("Content-Type")
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		entry.Response.Content = harContent{Size: rsp.ContentLength, MimeType: mimeType}
+		if sp != nil && (sp.BodyStream != nil || r.outStream != nil) {
+			entry.Response.Content.Comment = "stream body is not recorded"
+		} else if sp != nil {
+			entry.Response.Content.Size = int64(len(sp.Body))
+			if isTextMime(mimeType) {
+				entry.Response.Content.Text, entry.Response.Content.Comment = h.body(sp.Body)
+			} else {
+				entry.Response.Content.Comment = "binary body is not recorded"
+			}
+		}
+	}
+
+	data, e := sonic.Marshal(entry)
+	if e != nil {
+		return
+	}
+	h.write(data)
+}
+
+// 按配置截断body
+func (h *harRecorder) body(body string) (string, string) {
+	limit := h.conf.MaxBodyBytes
+	if limit < 0 {
+		return "", "body is not recorded"
+	}
+	if int64(len(body)) > limit {
+		return body[:limit], fmt.Sprintf("body truncated, %d of %d bytes", limit, len(body))
+	}
+	return body, ""
+}
+
+// 转换header并脱敏
+func (h *harRecorder) headers(header http.Header) []harNameValue {
+	ret := make([]harNameValue, 0, len(header))
+	for k, vs := range header {
+		redact := matchHeader(h.conf.RedactHeaders, k)
+		for _, v := range vs {
+			if redact {
+				v = redactedValue
+			}
+			ret = append(ret, harNameValue{Name: k, Value: v})
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+func harValues(values map[string][]string) []harNameValue {
+	ret := make([]harNameValue, 0, len(values))
+	for k, vs := range values {
+		for _, v := range vs {
+			ret = append(ret, harNameValue{Name: k, Value: v})
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+func isTextMime(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "json"), strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "xml"), strings.HasSuffix(mediaType, "yaml"),
+		mediaType == "application/x-www-form-urlencoded", mediaType == "application/javascript":
+		return true
+	}
+	return false
+}
+
+// 追加一条记录, 覆盖文件结尾后重新写入结尾, 使文件始终是完整的har
+func (h *harRecorder) write(entry []byte) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	empty := h.size <= int64(len(harHead)+len(harTail))
+	if h.f != nil && !empty && h.conf.MaxFileBytes > 0 && h.size+int64(len(entry)) > h.conf.MaxFileBytes {
+		_ = h.f.Close()
+		h.f = nil
+		h.rotate()
+	}
+	if h.f == nil && h.open() != nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	if h.size > int64(len(harHead)+len(harTail)) {
+		buf.WriteString(",\n")
+	}
+	buf.Write(entry)
+	buf.Write(harTail)
+	offset := h.size - int64(len(harTail))
+	if _, err := h.f.WriteAt(buf.Bytes(), offset); err != nil {
+		return
+	}
+	h.size = offset + int64(buf.Len())
+}
+
+// 打开har文件, 已存在且是完整的har时继续追加, 否则轮转后创建新文件
+func (h *harRecorder) open() error {
+	if err := os.MkdirAll(filepath.Dir(h.conf.Path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.conf.Path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if st.Size() > 0 {
+		if st.Size() >= int64(len(harHead)+len(harTail)) {
+			tail := make([]byte, len(harTail))
+			if _, err = f.ReadAt(tail, st.Size()-int64(len(tail))); err == nil && bytes.Equal(tail, harTail) {
+				h.f, h.size = f, st.Size()
+				return nil
+			}
+		}
+		_ = f.Close()
+		h.rotate()
+		if f, err = os.OpenFile(h.conf.Path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600); err != nil {
+			return err
+		}
+	}
+
+	if _, err = f.Write(append(append([]byte(nil), harHead...), harTail...)); err != nil {
+		_ = f.Close()
+		return err
+	}
+	h.f, h.size = f, int64(len(harHead)+len(harTail))
+	return nil
+}
+
+// 轮转文件, path -> path.1 -> path.2 ..., 超出 MaxBackups 的文件会被删除
+func (h *harRecorder) rotate() {
+	path := h.conf.Path
+	if h.conf.MaxBackups < 1 {
+		_ = os.Remove(path)
+		return
+	}
+	_ = os.Remove(fmt.Sprintf("%s.%d", path, h.conf.MaxBackups))
+	for i := h.conf.MaxBackups - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+	}
+	_ = os.Rename(path, path+".1")
+}
+
+func (h *harRecorder) Close() {
+	if h == nil {
+		return
+	}
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if h.f != nil {
+		_ = h.f.Close()
+		h.f = nil
+	}
+}
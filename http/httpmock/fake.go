@@ -0,0 +1,198 @@
+package httpmock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	stdhttp "net/http"
+	"sync"
+
+	"github.com/bytedance/sonic"
+
+	"github.com/zly-app/component/http"
+)
+
+// 没有匹配的预设响应时返回这个错误
+var ErrNoStub = errors.New("httpmock: no stub matches the request")
+
+// 可预设响应的客户端, 不会发送真实请求, 也不会经过 zapp filter.
+// 请求依然会经过 http 包的选项处理, 编解码, 中间件等流程, 因此可以直接替换业务代码中的 http.Client.
+// 熔断器和限流器不会与同名的客户端共享, 不再使用时需要调用 Close
+type FakeClient struct {
+	http.Client
+
+	mx       sync.Mutex
+	stubs    []*Stub
+	requests []*Request
+}
+
+// 创建可预设响应的客户端
+func NewFakeClient(name string) *FakeClient {
+	f := &FakeClient{}
+	c, err := http.NewClientWithRoundTripper(name, nil, stdhttp.RoundTripper(fakeTransport{f}))
+	if err != nil {
+		panic(err)
+	}
+	f.Client = c
+	return f
+}
+
+// 添加预设响应, 请求满足所有匹配器时使用. 按添加顺序匹配, 不传入匹配器表示匹配所有请求
+func (f *FakeClient) On(matchers ...Matcher) *Stub {
+	s := &Stub{
+		mx:      &f.mx,
+		matcher: MatchAll(matchers...),
+		rsp:     &Response{StatusCode: stdhttp.StatusOK},
+	}
+	f.mx.Lock()
+	f.stubs = append(f.stubs, s)
+	f.mx.Unlock()
+	return s
+}
+
+// 获取所有收到的请求
+func (f *FakeClient) Requests() []*Request {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return append([]*Request(nil), f.requests...)
+}
+
+// 清空预设响应和收到的请求
+func (f *FakeClient) Reset() {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.stubs = nil
+	f.requests = nil
+}
+
+func (f *FakeClient) Get(ctx context.Context, path string, opts ...http.Option) (*http.Response, error) {
+	return f.Client.Get(http.WithoutZAppFilter(ctx), path, opts...)
+}
+
+func (f *FakeClient) Head(ctx context.Context, path string, opts ...http.Option) (*http.Response, error) {
+	return f.Client.Head(http.WithoutZAppFilter(ctx), path, opts...)
+}
+
+func (f *FakeClient) Post(ctx context.Context, path string, reqBody []byte, opts ...http.Option) (*http.Response, error) {
+	return f.Client.Post(http.WithoutZAppFilter(ctx), path, reqBody, opts...)
+}
+
+func (f *FakeClient) Put(ctx context.Context, path string, reqBody []byte, opts ...http.Option) (*http.Response, error) {
+	return f.Client.Put(http.WithoutZAppFilter(ctx), path, reqBody, opts...)
+}
+
+func (f *FakeClient) Patch(ctx context.Context, path string, reqBody []byte, opts ...http.Option) (*http.Response, error) {
+	return f.Client.Patch(http.WithoutZAppFilter(ctx), path, reqBody, opts...)
+}
+
+func (f *FakeClient) Delete(ctx context.Context, path string, reqBody []byte, opts ...http.Option) (*http.Response, error) {
+	return f.Client.Delete(http.WithoutZAppFilter(ctx), path, reqBody, opts...)
+}
+
+func (f *FakeClient) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	return f.Client.Do(http.WithoutZAppFilter(ctx), req)
+}
+
+func (f *FakeClient) Download(ctx context.Context, url, dstPath string, opts ...http.Option) (*http.Response, error) {
+	return f.Client.Download(http.WithoutZAppFilter(ctx), url, dstPath, opts...)
+}
+
+func (f *FakeClient) roundTrip(req *stdhttp.Request) (*stdhttp.Response, error) {
+	captured, err := captureRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mx.Lock()
+	f.requests = append(f.requests, captured)
+	var stub *Stub
+	for _, s := range f.stubs {
+		if s.take(captured) {
+			stub = s
+			break
+		}
+	}
+	f.mx.Unlock()
+
+	if stub == nil {
+		return nil, fmt.Errorf("%w: %s %s%s", ErrNoStub, captured.Method, captured.Host, captured.Path)
+	}
+	if stub.err != nil {
+		return nil, stub.err
+	}
+	return stub.rsp.toStd(req), nil
+}
+
+type fakeTransport struct {
+	f *FakeClient
+}
+
+func (t fakeTransport) RoundTrip(req *stdhttp.Request) (*stdhttp.Response, error) {
+	return t.f.roundTrip(req)
+}
+
+// 预设响应
+type Stub struct {
+	mx      *sync.Mutex // 所属 FakeClient 的锁
+	matcher Matcher
+	rsp     *Response
+	err     error
+	times   int // 可以使用的次数, 0表示不限制
+	hits    int
+}
+
+// 是否匹配且还有剩余次数, 匹配时会增加使用次数
+func (s *Stub) take(r *Request) bool {
+	if s.times > 0 && s.hits >= s.times {
+		return false
+	}
+	if !s.matcher(r) {
+		return false
+	}
+	s.hits++
+	return true
+}
+
+// 设置响应状态码和body
+func (s *Stub) Reply(statusCode int, body string) *Stub {
+	s.rsp.StatusCode = statusCode
+	s.rsp.Body, s.rsp.BodyBase64 = encodeBody([]byte(body))
+	return s
+}
+
+// 设置响应状态码和json数据, 会设置 Content-Type 为 application/json
+func (s *Stub) ReplyJson(statusCode int, v interface{}) *Stub {
+	body, err := sonic.ConfigStd.Marshal(v)
+	if err != nil {
+		panic(fmt.Errorf("httpmock: 序列化json失败: %v", err))
+	}
+	return s.Reply(statusCode, string(body)).ReplyHeader("Content-Type", "application/json")
+}
+
+// 添加响应header
+func (s *Stub) ReplyHeader(key, value string) *Stub {
+	if s.rsp.Header == nil {
+		s.rsp.Header = make(stdhttp.Header)
+	}
+	s.rsp.Header.Add(key, value)
+	return s
+}
+
+// 返回错误, 模拟网络错误等情况
+func (s *Stub) ReplyError(err error) *Stub {
+	s.err = err
+	return s
+}
+
+// 限制可以使用的次数, 用完后会继续匹配后面的预设响应
+func (s *Stub) Times(n int) *Stub {
+	s.times = n
+	return s
+}
+
+// 已经使用的次数
+func (s *Stub) Hits() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.hits
+}
@@ -0,0 +1,110 @@
+package httpmock
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/zly-app/component/http"
+)
+
+func newTestFakeClient(t *testing.T) *FakeClient {
+	f := NewFakeClient("test-fake")
+	t.Cleanup(f.Close)
+	return f
+}
+
+func TestFakeClientStub(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFakeClient(t)
+	user := f.On(MatchMethod("GET"), MatchPath("/user"), MatchParam("id", "1")).
+		ReplyJson(200, map[string]interface{}{"name": "zly"})
+	once := f.On(MatchPath("/once")).Reply(201, "first").Times(1)
+	f.On(MatchPath("/once")).Reply(200, "later")
+	netErr := errors.New("connection reset")
+	f.On(MatchPath("/error")).ReplyError(netErr)
+
+	var out struct {
+		Name string `json:"name"`
+	}
+	sp, err := f.Get(ctx, "http://api/user", http.WithInParams(url.Values{"id": {"1"}}), http.WithOutJson(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp.StatusCode != 200 || out.Name != "zly" || sp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("status = %d, name = %q, header = %v", sp.StatusCode, out.Name, sp.Header)
+	}
+
+	tests := []struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{path: "/once", wantCode: 201, wantBody: "first"},
+		{path: "/once", wantCode: 200, wantBody: "later"},
+		{path: "/once", wantCode: 200, wantBody: "later"},
+	}
+	for i, tt := range tests {
+		sp, err = f.Get(ctx, "http://api"+tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sp.StatusCode != tt.wantCode || sp.Body != tt.wantBody {
+			t.Errorf("request %d = %d %q, want %d %q", i+1, sp.StatusCode, sp.Body, tt.wantCode, tt.wantBody)
+		}
+	}
+	if user.Hits() != 1 || once.Hits() != 1 {
+		t.Errorf("hits = %d, %d, want 1, 1", user.Hits(), once.Hits())
+	}
+
+	if _, err = f.Get(ctx, "http://api/error"); !errors.Is(err, netErr) {
+		t.Errorf("err = %v, want %v", err, netErr)
+	}
+	if _, err = f.Get(ctx, "http://api/user"); !errors.Is(err, ErrNoStub) {
+		t.Errorf("err = %v, want ErrNoStub", err)
+	}
+}
+
+func TestFakeClientRequests(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFakeClient(t)
+	f.On().Reply(200, "ok")
+
+	var calls int
+	f.Use(func(next http.Handler) http.Handler {
+		return func(ctx context.Context, r *http.Request) (*http.Response, error) {
+			calls++
+			return next(ctx, r)
+		}
+	})
+	_, err := f.Post(ctx, "http://api/a", []byte(`{"a":1}`), http.WithInHeader(http.Header{"X-App": {"demo"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Delete(ctx, "http://api/b", nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("middleware calls = %d, want 2", calls)
+	}
+
+	reqs := f.Requests()
+	if len(reqs) != 2 {
+		t.Fatalf("requests = %d, want 2", len(reqs))
+	}
+	if r := reqs[0]; r.Method != "POST" || r.Path != "/a" || r.Body != `{"a":1}` || r.Header.Get("X-App") != "demo" {
+		t.Errorf("request 1 = %+v", r)
+	}
+	if r := reqs[1]; r.Method != "DELETE" || r.Path != "/b" {
+		t.Errorf("request 2 = %+v", r)
+	}
+
+	f.Reset()
+	if len(f.Requests()) != 0 {
+		t.Error("requests are not cleared after Reset")
+	}
+	if _, err = f.Get(ctx, "http://api/a"); !errors.Is(err, ErrNoStub) {
+		t.Errorf("err = %v, want ErrNoStub after Reset", err)
+	}
+}
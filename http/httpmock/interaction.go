@@ -0,0 +1,222 @@
+// 提供不依赖网络的 http.Client 测试工具, 包括可预设响应的 FakeClient 和录制/回放请求的 Recorder
+package httpmock
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	stdhttp "net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bytedance/sonic"
+)
+
+// 一次请求和它的响应
+type Interaction struct {
+	Request  *Request  `json:"request"`
+	Response *Response `json:"response"`
+}
+
+// 捕获的请求
+type Request struct {
+	Method     string         `json:"method"`
+	Host       string         `json:"host"`
+	Path       string         `json:"path"`
+	Params     url.Values     `json:"params,omitempty"`
+	Header     stdhttp.Header `json:"header,omitempty"`
+	Body       string         `json:"body,omitempty"`
+	BodyBase64 bool           `json:"bodyBase64,omitempty"` // Body 不是有效的utf8时使用base64编码保存
+}
+
+// 捕获的响应
+type Response struct {
+	StatusCode int            `json:"statusCode"`
+	Header     stdhttp.Header `json:"header,omitempty"`
+	Body       string         `json:"body,omitempty"`
+	BodyBase64 bool           `json:"bodyBase64,omitempty"` // Body 不是有效的utf8时使用base64编码保存
+}
+
+// 获取原始的请求body
+func (r *Request) BodyBytes() []byte {
+	return decodeBody(r.Body, r.BodyBase64)
+}
+
+// 获取原始的响应body
+func (r *Response) BodyBytes() []byte {
+	return decodeBody(r.Body, r.BodyBase64)
+}
+
+// 转为标准库的响应
+func (r *Response) toStd(req *stdhttp.Request) *stdhttp.Response {
+	body := r.BodyBytes()
+	header := r.Header.Clone()
+	if header == nil {
+		header = make(stdhttp.Header)
+	}
+	return &stdhttp.Response{
+		Status:        stdhttp.StatusText(r.StatusCode),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func encodeBody(body []byte) (string, bool) {
+	if utf8.Valid(body) {
+		return string(body), false
+	}
+	return base64.StdEncoding.EncodeToString(body), true
+}
+
+func decodeBody(body string, isBase64 bool) []byte {
+	if !isBase64 {
+		return []byte(body)
+	}
+	bs, _ := base64.StdEncoding.DecodeString(body)
+	return bs
+}
+
+// 捕获请求, 读取后会恢复请求的body
+func captureRequest(req *stdhttp.Request) (*Request, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	r := &Request{
+		Method: req.Method,
+		Host:   req.URL.Host,
+		Path:   req.URL.Path,
+		Params: req.URL.Query(),
+		Header: req.Header.Clone(),
+	}
+	if len(r.Params) == 0 {
+		r.Params = nil
+	}
+	r.Body, r.BodyBase64 = encodeBody(body)
+	return r, nil
+}
+
+// 捕获响应, 读取后会恢复响应的body
+func captureResponse(rsp *stdhttp.Response) (*Response, error) {
+	body, err := io.ReadAll(rsp.Body)
+	_ = rsp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	rsp.Body = io.NopCloser(bytes.NewReader(body))
+
+	r := &Response{
+		StatusCode: rsp.StatusCode,
+		Header:     rsp.Header.Clone(),
+	}
+	r.Body, r.BodyBase64 = encodeBody(body)
+	return r, nil
+}
+
+// 请求匹配器
+type Matcher func(r *Request) bool
+
+// 匹配所有条件
+func MatchAll(matchers ...Matcher) Matcher {
+	return func(r *Request) bool {
+		for _, m := range matchers {
+			if !m(r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// 匹配请求方法
+func MatchMethod(method string) Matcher {
+	return func(r *Request) bool {
+		return strings.EqualFold(r.Method, method)
+	}
+}
+
+// 匹配请求路径, 不包含参数
+func MatchPath(path string) Matcher {
+	return func(r *Request) bool {
+		return r.Path == path
+	}
+}
+
+// 匹配请求路径前缀
+func MatchPathPrefix(prefix string) Matcher {
+	return func(r *Request) bool {
+		return strings.HasPrefix(r.Path, prefix)
+	}
+}
+
+// 匹配请求参数
+func MatchParam(key, value string) Matcher {
+	return func(r *Request) bool {
+		for _, v := range r.Params[key] {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// 匹配所有请求参数完全相同
+func MatchParams(params url.Values) Matcher {
+	return func(r *Request) bool {
+		return r.Params.Encode() == params.Encode()
+	}
+}
+
+// 匹配请求header
+func MatchHeader(key, value string) Matcher {
+	return func(r *Request) bool {
+		for _, v := range r.Header.Values(key) {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// 匹配请求body完全相同
+func MatchBody(body string) Matcher {
+	return func(r *Request) bool {
+		return string(r.BodyBytes()) == body
+	}
+}
+
+// 匹配请求body的json语义相同, 忽略字段顺序和空白
+func MatchJsonBody(body string) Matcher {
+	var expect interface{}
+	expectErr := sonic.UnmarshalString(body, &expect)
+	return func(r *Request) bool {
+		var actual interface{}
+		if expectErr != nil || sonic.Unmarshal(r.BodyBytes(), &actual) != nil {
+			return false
+		}
+		a, _ := sonic.ConfigStd.Marshal(expect)
+		b, _ := sonic.ConfigStd.Marshal(actual)
+		return bytes.Equal(a, b)
+	}
+}
+
+// 匹配自定义条件
+func MatchFunc(fn func(r *Request) bool) Matcher {
+	return fn
+}
@@ -0,0 +1,125 @@
+package httpmock
+
+import (
+	"io"
+	stdhttp "net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestEncodeBody(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       []byte
+		wantBase64 bool
+	}{
+		{name: "empty", body: nil},
+		{name: "utf8", body: []byte(`{"name":"张三"}`)},
+		{name: "binary", body: []byte{0xff, 0xfe, 0x00, 0x01}, wantBase64: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, isBase64 := encodeBody(tt.body)
+			if isBase64 != tt.wantBase64 {
+				t.Errorf("base64 = %v, want %v", isBase64, tt.wantBase64)
+			}
+			if got := decodeBody(s, isBase64); string(got) != string(tt.body) {
+				t.Errorf("decoded body = %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
+
+func TestCaptureRequest(t *testing.T) {
+	req, _ := stdhttp.NewRequest("POST", "http://example.com/a/b?x=1&x=2", strings.NewReader("hello"))
+	req.Header.Set("X-App", "demo")
+	r, err := captureRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Method != "POST" || r.Host != "example.com" || r.Path != "/a/b" {
+		t.Errorf("captured %s %s%s, want POST example.com/a/b", r.Method, r.Host, r.Path)
+	}
+	if got := r.Params.Encode(); got != "x=1&x=2" {
+		t.Errorf("params = %s, want x=1&x=2", got)
+	}
+	if r.Header.Get("X-App") != "demo" || r.Body != "hello" {
+		t.Errorf("header = %v, body = %q", r.Header, r.Body)
+	}
+	// 捕获后body可以再次读取
+	if body, _ := io.ReadAll(req.Body); string(body) != "hello" {
+		t.Errorf("request body after capture = %q, want hello", body)
+	}
+
+	req, _ = stdhttp.NewRequest("GET", "http://example.com/", nil)
+	if r, err = captureRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if r.Params != nil || r.Body != "" {
+		t.Errorf("params = %v, body = %q, want empty", r.Params, r.Body)
+	}
+}
+
+func TestResponseToStd(t *testing.T) {
+	r := &Response{StatusCode: 201, Header: stdhttp.Header{"X-Id": {"1"}}}
+	r.Body, r.BodyBase64 = encodeBody([]byte{0xff, 0x00})
+	req, _ := stdhttp.NewRequest("GET", "http://example.com/", nil)
+	rsp := r.toStd(req)
+	body, _ := io.ReadAll(rsp.Body)
+	if rsp.StatusCode != 201 || rsp.Status != "Created" || rsp.Header.Get("X-Id") != "1" || rsp.Request != req {
+		t.Errorf("response = %+v", rsp)
+	}
+	if string(body) != "\xff\x00" || rsp.ContentLength != 2 {
+		t.Errorf("body = %q, ContentLength = %d", body, rsp.ContentLength)
+	}
+	// 修改返回的header不影响预设的响应
+	rsp.Header.Set("X-Id", "2")
+	if r.Header.Get("X-Id") != "1" {
+		t.Error("toStd shares header with the stub")
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	r := &Request{
+		Method: "POST",
+		Host:   "example.com",
+		Path:   "/api/user",
+		Params: url.Values{"id": {"1", "2"}, "q": {"a"}},
+		Header: stdhttp.Header{"X-App": {"demo"}},
+		Body:   `{"a":1, "b":[1,2]}`,
+	}
+	tests := []struct {
+		name    string
+		matcher Matcher
+		want    bool
+	}{
+		{name: "method", matcher: MatchMethod("post"), want: true},
+		{name: "method mismatch", matcher: MatchMethod("GET"), want: false},
+		{name: "path", matcher: MatchPath("/api/user"), want: true},
+		{name: "path mismatch", matcher: MatchPath("/api"), want: false},
+		{name: "path prefix", matcher: MatchPathPrefix("/api/"), want: true},
+		{name: "param", matcher: MatchParam("id", "2"), want: true},
+		{name: "param mismatch", matcher: MatchParam("id", "3"), want: false},
+		{name: "params", matcher: MatchParams(url.Values{"q": {"a"}, "id": {"1", "2"}}), want: true},
+		{name: "params subset", matcher: MatchParams(url.Values{"q": {"a"}}), want: false},
+		{name: "header", matcher: MatchHeader("x-app", "demo"), want: true},
+		{name: "header mismatch", matcher: MatchHeader("X-App", "other"), want: false},
+		{name: "body", matcher: MatchBody(`{"a":1, "b":[1,2]}`), want: true},
+		{name: "body mismatch", matcher: MatchBody(`{"a":1,"b":[1,2]}`), want: false},
+		{name: "json body", matcher: MatchJsonBody(`{"b": [1, 2], "a": 1}`), want: true},
+		{name: "json body mismatch", matcher: MatchJsonBody(`{"a":1,"b":[2,1]}`), want: false},
+		{name: "invalid json", matcher: MatchJsonBody(`{`), want: false},
+		{name: "func", matcher: MatchFunc(func(r *Request) bool { return r.Host == "example.com" }), want: true},
+		{name: "all", matcher: MatchAll(MatchMethod("POST"), MatchPath("/api/user")), want: true},
+		{name: "all mismatch", matcher: MatchAll(MatchMethod("POST"), MatchPath("/api")), want: false},
+		{name: "all empty", matcher: MatchAll(), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.matcher(r); got != tt.want {
+				t.Errorf("match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
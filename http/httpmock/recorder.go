@@ -0,0 +1,166 @@
+package httpmock
+
+import (
+	"errors"
+	"fmt"
+	stdhttp "net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/bytedance/sonic"
+)
+
+// 回放时没有匹配的录制记录时返回这个错误
+var ErrNoInteraction = errors.New("httpmock: no recorded interaction matches the request")
+
+// 录制模式
+type Mode int
+
+const (
+	ModeReplay Mode = iota // 从录制文件回放, 不会发送真实请求
+	ModeRecord             // 发送真实请求并录制, 调用 Save 后写入录制文件
+	ModeAuto               // 录制文件存在时回放, 否则录制
+)
+
+// 默认录制时不保存的请求header
+var DefaultSkipHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// 录制/回放请求的 RoundTripper, 可以通过 http.NewClientWithRoundTripper 接入客户端
+type Recorder struct {
+	// 录制时用于发送真实请求, 默认为 stdhttp.DefaultTransport
+	Transport stdhttp.RoundTripper
+	// 回放时判断请求和录制的请求是否匹配, 默认比较 method, host, path, params, body
+	Match func(req, recorded *Request) bool
+	// 录制时不保存的请求header, 默认为 DefaultSkipHeaders
+	SkipHeaders []string
+
+	path string
+	mode Mode
+
+	mx           sync.Mutex
+	interactions []*Interaction
+	used         []bool
+}
+
+// 创建录制器, 回放模式会立即加载录制文件
+func NewRecorder(path string, mode Mode) (*Recorder, error) {
+	if mode == ModeAuto {
+		mode = ModeRecord
+		if _, err := os.Stat(path); err == nil {
+			mode = ModeReplay
+		}
+	}
+
+	r := &Recorder{
+		SkipHeaders: DefaultSkipHeaders,
+		path:        path,
+		mode:        mode,
+	}
+	if mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取录制文件失败: %v", err)
+		}
+		if err = sonic.Unmarshal(data, &r.interactions); err != nil {
+			return nil, fmt.Errorf("解析录制文件失败: %v", err)
+		}
+		r.used = make([]bool, len(r.interactions))
+	}
+	return r, nil
+}
+
+// 当前的模式, ModeAuto 会被解析为 ModeReplay 或 ModeRecord
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// 已录制或加载的记录
+func (r *Recorder) Interactions() []*Interaction {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return append([]*Interaction(nil), r.interactions...)
+}
+
+func (r *Recorder) RoundTrip(req *stdhttp.Request) (*stdhttp.Response, error) {
+	captured, err := captureRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode == ModeReplay {
+		return r.replay(req, captured)
+	}
+	return r.record(req, captured)
+}
+
+func (r *Recorder) record(req *stdhttp.Request, captured *Request) (*stdhttp.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = stdhttp.DefaultTransport
+	}
+	rsp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	capturedRsp, err := captureResponse(rsp)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range r.SkipHeaders {
+		captured.Header.Del(h)
+	}
+	if len(captured.Header) == 0 {
+		captured.Header = nil
+	}
+
+	r.mx.Lock()
+	r.interactions = append(r.interactions, &Interaction{Request: captured, Response: capturedRsp})
+	r.mx.Unlock()
+	return rsp, nil
+}
+
+// 按录制顺序找到第一个未使用的匹配记录, 保证相同请求多次回放的结果是确定的
+func (r *Recorder) replay(req *stdhttp.Request, captured *Request) (*stdhttp.Response, error) {
+	match := r.Match
+	if match == nil {
+		match = defaultMatch
+	}
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	for i, it := range r.interactions {
+		if r.used[i] || !match(captured, it.Request) {
+			continue
+		}
+		r.used[i] = true
+		return it.Response.toStd(req), nil
+	}
+	return nil, fmt.Errorf("%w: %s %s%s", ErrNoInteraction, captured.Method, captured.Host, captured.Path)
+}
+
+func defaultMatch(req, recorded *Request) bool {
+	return req.Method == recorded.Method &&
+		req.Host == recorded.Host &&
+		req.Path == recorded.Path &&
+		req.Params.Encode() == recorded.Params.Encode() &&
+		string(req.BodyBytes()) == string(recorded.BodyBytes())
+}
+
+// 将录制的记录写入录制文件, 回放模式下不做任何事
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mx.Lock()
+	data, err := sonic.ConfigStd.MarshalIndent(r.interactions, "", "  ")
+	r.mx.Unlock()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0o644)
+}
@@ -0,0 +1,160 @@
+package httpmock
+
+import (
+	"context"
+	"errors"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/zly-app/component/http"
+)
+
+func newRecorderTestClient(t *testing.T, rec *Recorder) http.Client {
+	c, err := http.NewClientWithRoundTripper("test-recorder", nil, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestRecorder(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		w.Header().Set("X-Hit", strconv.Itoa(int(n)))
+		if r.URL.Path == "/bin" {
+			_, _ = w.Write([]byte{0xff, 0x00})
+			return
+		}
+		_, _ = w.Write([]byte("hit " + strconv.Itoa(int(n))))
+	}))
+	path := filepath.Join(t.TempDir(), "testdata", "rec.json")
+	ctx := http.WithoutZAppFilter(context.Background())
+
+	// 录制, 相同的请求录制两次
+	rec, err := NewRecorder(path, ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Mode() != ModeRecord {
+		t.Fatalf("mode = %v, want ModeRecord", rec.Mode())
+	}
+	c := newRecorderTestClient(t, rec)
+	auth := http.WithInHeader(http.Header{"Authorization": {"Bearer token"}, "X-App": {"demo"}})
+	for _, p := range []string{"/a", "/a", "/bin"} {
+		if _, err = c.Get(ctx, srv.URL+p, auth); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	its := rec.Interactions()
+	if len(its) != 3 {
+		t.Fatalf("interactions = %d, want 3", len(its))
+	}
+	if h := its[0].Request.Header; h.Get("Authorization") != "" || h.Get("X-App") != "demo" {
+		t.Errorf("recorded header = %v, want Authorization skipped", h)
+	}
+	if !its[2].Response.BodyBase64 {
+		t.Error("binary body is not base64 encoded")
+	}
+
+	// 回放, 服务已关闭, 相同的请求按录制顺序返回
+	rec, err = NewRecorder(path, ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Mode() != ModeReplay {
+		t.Fatalf("mode = %v, want ModeReplay", rec.Mode())
+	}
+	c = newRecorderTestClient(t, rec)
+	tests := []struct {
+		path     string
+		wantBody string
+		wantHit  string
+	}{
+		{path: "/a", wantBody: "hit 1", wantHit: "1"},
+		{path: "/a", wantBody: "hit 2", wantHit: "2"},
+		{path: "/bin", wantBody: "\xff\x00", wantHit: "3"},
+	}
+	for _, tt := range tests {
+		sp, err := c.Get(ctx, srv.URL+tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sp.Body != tt.wantBody || sp.Header.Get("X-Hit") != tt.wantHit {
+			t.Errorf("replay %s = %q, X-Hit %s, want %q, %s", tt.path, sp.Body, sp.Header.Get("X-Hit"), tt.wantBody, tt.wantHit)
+		}
+	}
+	if _, err = c.Get(ctx, srv.URL+"/a"); !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("err = %v, want ErrNoInteraction", err)
+	}
+	if hits := atomic.LoadInt32(&hits); hits != 3 {
+		t.Errorf("server hits = %d, want 3", hits)
+	}
+
+	// 回放模式下 Save 不修改录制文件
+	before, _ := os.ReadFile(path)
+	if err = rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Error("Save in replay mode changes the file")
+	}
+}
+
+func TestRecorderReplayMatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rec.json")
+	data := `[{"request":{"method":"POST","host":"api","path":"/a","body":"x"},"response":{"statusCode":200,"body":"ok"}}]`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := http.WithoutZAppFilter(context.Background())
+
+	tests := []struct {
+		name    string
+		match   func(req, recorded *Request) bool
+		body    string
+		wantErr bool
+	}{
+		{name: "default match", body: "x"},
+		{name: "body mismatch", body: "y", wantErr: true},
+		{name: "custom match", match: func(req, recorded *Request) bool { return req.Path == recorded.Path }, body: "y"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, err := NewRecorder(path, ModeReplay)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec.Match = tt.match
+			c := newRecorderTestClient(t, rec)
+			sp, err := c.Post(ctx, "http://api/a", []byte(tt.body))
+			if tt.wantErr {
+				if !errors.Is(err, ErrNoInteraction) {
+					t.Errorf("err = %v, want ErrNoInteraction", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sp.Body != "ok" {
+				t.Errorf("body = %q, want ok", sp.Body)
+			}
+		})
+	}
+
+	if _, err := NewRecorder(filepath.Join(t.TempDir(), "missing.json"), ModeReplay); err == nil {
+		t.Error("NewRecorder with missing file in replay mode returns no error")
+	}
+}
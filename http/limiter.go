@@ -0,0 +1,313 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 超出限流或并发限制时返回这个错误
+var ErrRateLimited = errors.New("http: rate limited")
+
+// host级别的限流器空闲超过这个时间后会被删除, 避免host数量不受限制时限流器一直增长
+const limiterIdleTimeout = 10 * time.Minute
+
+// 限流器状态统计
+type LimiterStats struct {
+	Client      string  // 客户端名
+	Host        string  // 请求的host, 为空表示客户端级别的限制
+	QPS         float64 // 每秒请求数, 0表示不限制
+	Burst       int     // 令牌桶容量
+	Tokens      float64 // 当前剩余令牌数, 为负数表示已被等待中的请求预占
+	MaxInflight int     // 最大并发数, 0表示不限制
+	Inflight    int64   // 当前并发数
+	Waiting     int64   // 正在等待的请求数
+	Rejected    int64   // 累计被拒绝的请求数
+}
+
+type limiterKey struct {
+	client string
+	conn   uint64 // 连接编号, 隔离 NewClientWithRoundTripper 创建的同名客户端
+	host   string
+}
+
+// 所有限流器, 按 (客户端名, 连接编号, host) 区分, host为空表示客户端级别
+var limiters sync.Map // limiterKey -> *limiter
+
+// 获取所有限流器的状态, 按客户端名和host排序
+func GetLimiterStats() []LimiterStats {
+	var ret []LimiterStats
+	limiters.Range(func(key, value any) bool {
+		ret = append(ret, value.(*limiter).stats())
+		return true
+	})
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Client != ret[j].Client {
+			return ret[i].Client < ret[j].Client
+		}
+		return ret[i].Host < ret[j].Host
+	})
+	return ret
+}
+
+func getLimiter(key limiterKey, qps float64, burst, maxInflight int) *limiter {
+	if qps <= 0 && maxInflight <= 0 {
+		return nil
+	}
+	v, ok := limiters.Load(key)
+	if !ok {
+		var loaded bool
+		v, loaded = limiters.LoadOrStore(key, newLimiter(key, qps, burst, maxInflight))
+		if !loaded && key.host != "" {
+			evictLimiters(time.Now())
+		}
+	}
+	l := v.(*limiter)
+	l.lastUsed.Store(time.Now().UnixNano())
+	return l
+}
+
+// 上次删除空闲限流器的时间
+var limitersEvictedAt atomic.Int64
+
+// 删除空闲的host级别限流器, 最多每分钟检查一次. 空闲的限流器令牌桶已经填满, 删除后重新创建不影响限流
+func evictLimiters(now time.Time) {
+	last := limitersEvictedAt.Load()
+	if now.UnixNano()-last < int64(time.Minute) || !limitersEvictedAt.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	limiters.Range(func(key, value any) bool {
+		if value.(*limiter).idle(now) {
+			limiters.CompareAndDelete(key, value)
+		}
+		return true
+	})
+}
+
+// 删除客户端的所有限流器
+func removeLimiters(client string, conn uint64) {
+	limiters.Range(func(key, value any) bool {
+		if k := key.(limiterKey); k.client == client && k.conn == conn {
+			limiters.Delete(key)
+		}
+		return true
+	})
+}
+
+// 令牌桶限流和并发限制
+type limiter struct {
+	key         limiterKey
+	qps         float64
+	burst       int
+	maxInflight int
+	sem         chan struct{}
+
+	mx       sync.Mutex
+	tokens   float64
+	last     time.Time
+	waiting  atomic.Int64
+	rejected atomic.Int64
+	lastUsed atomic.Int64 // 最后一次获取限流器的时间(纳秒)
+}
+
+func newLimiter(key limiterKey, qps float64, burst, maxInflight int) *limiter {
+	if burst < 1 {
+		burst = max(1, int(math.Ceil(qps)))
+	}
+	l := &limiter{
+		key:         key,
+		qps:         qps,
+		burst:       burst,
+		maxInflight: maxInflight,
+		tokens:      float64(burst),
+		last:        time.Now(),
+	}
+	if maxInflight > 0 {
+		l.sem = make(chan struct{}, maxInflight)
+	}
+	l.lastUsed.Store(l.last.UnixNano())
+	return l
+}
+
+func (l *limiter) refill(now time.Time) {
+	l.tokens = math.Min(float64(l.burst), l.tokens+now.Sub(l.last).Seconds()*l.qps)
+	l.last = now
+}
+
+// 获取令牌, failFast 为true, 等待时间超过ctx截止时间或等待中ctx超时时返回 ErrRateLimited
+func (l *limiter) wait(ctx context.Context, failFast bool) error {
+	if l.qps <= 0 {
+		return nil
+	}
+
+	l.mx.Lock()
+	now := time.Now()
+	l.refill(now)
+	if l.tokens >= 1 {
+		l.tokens--
+		l.mx.Unlock()
+		return nil
+	}
+	d := time.Duration((1 - l.tokens) / l.qps * float64(time.Second))
+	if deadline, ok := ctx.Deadline(); failFast || (ok && deadline.Before(now.Add(d))) {
+		l.mx.Unlock()
+		l.rejected.Add(1)
+		return ErrRateLimited
+	}
+	l.tokens-- // 预占令牌
+	l.mx.Unlock()
+
+	l.waiting.Add(1)
+	defer l.waiting.Add(-1)
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.refund() // 归还预占的令牌
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			l.rejected.Add(1)
+			return ErrRateLimited
+		}
+		return ctx.Err()
+	}
+}
+
+// 归还获取的令牌, 请求没有发送时调用
+func (l *limiter) refund() {
+	if l.qps <= 0 {
+		return
+	}
+	l.mx.Lock()
+	l.tokens = math.Min(float64(l.burst), l.tokens+1)
+	l.mx.Unlock()
+}
+
+// 占用并发名额, failFast 为true时名额不足立即返回 ErrRateLimited
+func (l *limiter) acquire(ctx context.Context, failFast bool) error {
+	if l.sem == nil {
+		return nil
+	}
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	default:
+	}
+	if failFast {
+		l.rejected.Add(1)
+		return ErrRateLimited
+	}
+
+	l.waiting.Add(1)
+	defer l.waiting.Add(-1)
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			l.rejected.Add(1)
+			return ErrRateLimited
+		}
+		return ctx.Err()
+	}
+}
+
+func (l *limiter) release() {
+	if l.sem != nil {
+		<-l.sem
+	}
+}
+
+// 是否为空闲的host级别限流器
+func (l *limiter) idle(now time.Time) bool {
+	if l.key.host == "" || len(l.sem) > 0 || l.waiting.Load() > 0 {
+		return false
+	}
+	timeout := limiterIdleTimeout
+	if l.qps > 0 {
+		// 等待令牌桶填满
+		timeout = max(timeout, time.Duration(float64(l.burst)/l.qps*float64(time.Second)))
+	}
+	return now.Sub(time.Unix(0, l.lastUsed.Load())) > timeout
+}
+
+func (l *limiter) stats() LimiterStats {
+	l.mx.Lock()
+	l.refill(time.Now())
+	tokens := l.tokens
+	l.mx.Unlock()
+	return LimiterStats{
+		Client:      l.key.client,
+		Host:        l.key.host,
+		QPS:         l.qps,
+		Burst:       l.burst,
+		Tokens:      tokens,
+		MaxInflight: l.maxInflight,
+		Inflight:    int64(len(l.sem)),
+		Waiting:     l.waiting.Load(),
+		Rejected:    l.rejected.Load(),
+	}
+}
+
+// 按客户端和host的限制获取令牌和并发名额, 返回请求结束后需要调用的释放函数
+func acquireLimit(ctx context.Context, conn *clientConn, host string) (release func(), err error) {
+	conf := &conn.conf.RateLimit
+	if maxWait := time.Duration(conf.MaxWait) * time.Millisecond; maxWait > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, maxWait)
+		defer cancel()
+	}
+
+	var acquired []*limiter
+	release = func() {
+		for _, l := range acquired {
+			l.release()
+		}
+	}
+	// 获取失败时归还已获取的令牌和并发名额
+	fail := func(took *limiter) {
+		release()
+		for _, l := range append(acquired, took) {
+			if l != nil {
+				l.refund()
+			}
+		}
+	}
+	for _, l := range []*limiter{
+		getLimiter(limiterKey{client: conn.name, conn: conn.id}, conf.QPS, conf.Burst, conf.MaxInflight),
+		getLimiter(limiterKey{client: conn.name, conn: conn.id, host: host}, conf.HostQPS, conf.HostBurst, conf.HostMaxInflight),
+	} {
+		if l == nil {
+			continue
+		}
+		if err = l.wait(ctx, conf.FailFast); err != nil {
+			fail(nil)
+			return nil, err
+		}
+		if err = l.acquire(ctx, conf.FailFast); err != nil {
+			fail(l)
+			return nil, err
+		}
+		acquired = append(acquired, l)
+	}
+	return release, nil
+}
+
+// 关闭时调用释放函数
+type releaseReadCloser struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
@@ -0,0 +1,221 @@
+package http
+
+import (
+	"context"
+	"errors"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// 没有截止时间但会超时的ctx, 用于测试等待中超时
+type noDeadlineCtx struct{ context.Context }
+
+func (noDeadlineCtx) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func TestLimiterWait(t *testing.T) {
+	tests := []struct {
+		name     string
+		failFast bool
+		ctx      func() (context.Context, context.CancelFunc)
+		wantErr  error
+		rejected int64
+	}{
+		{
+			name:     "fail fast",
+			failFast: true,
+			ctx:      func() (context.Context, context.CancelFunc) { return context.Background(), func() {} },
+			wantErr:  ErrRateLimited,
+			rejected: 1,
+		},
+		{
+			name: "deadline before token",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 10*time.Millisecond)
+			},
+			wantErr:  ErrRateLimited,
+			rejected: 1,
+		},
+		{
+			name: "deadline exceeded while waiting",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+				return noDeadlineCtx{ctx}, cancel
+			},
+			wantErr:  ErrRateLimited,
+			rejected: 1,
+		},
+		{
+			name: "canceled while waiting",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(10*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			wantErr: context.Canceled,
+		},
+		{
+			name: "wait for token",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Second)
+			},
+			wantErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLimiter(limiterKey{client: "test"}, 20, 1, 0)
+			if err := l.wait(context.Background(), tt.failFast); err != nil {
+				t.Fatalf("first wait: %v", err)
+			}
+
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			err := l.wait(ctx, tt.failFast)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("wait err = %v, want %v", err, tt.wantErr)
+			}
+			if got := l.stats().Rejected; got != tt.rejected {
+				t.Errorf("rejected = %d, want %d", got, tt.rejected)
+			}
+			if tt.wantErr != nil && l.stats().Tokens < -0.5 {
+				t.Errorf("reserved token is not returned, tokens = %v", l.stats().Tokens)
+			}
+		})
+	}
+}
+
+func TestLimiterAcquire(t *testing.T) {
+	l := newLimiter(limiterKey{client: "test"}, 0, 0, 1)
+	if err := l.acquire(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.acquire(context.Background(), true); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("fail fast err = %v, want ErrRateLimited", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.acquire(ctx, false); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("timeout err = %v, want ErrRateLimited", err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := l.acquire(ctx, false); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled err = %v, want context.Canceled", err)
+	}
+	if got := l.stats().Rejected; got != 2 {
+		t.Errorf("rejected = %d, want 2", got)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- l.acquire(context.Background(), false) }()
+	time.Sleep(10 * time.Millisecond)
+	l.release()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquire is not woken up by release")
+	}
+	if got := l.stats().Inflight; got != 1 {
+		t.Errorf("inflight = %d, want 1", got)
+	}
+}
+
+func TestRateLimitMaxWait(t *testing.T) {
+	srv := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {}))
+	defer srv.Close()
+
+	conf := newConfig()
+	conf.RateLimit.QPS = 1
+	conf.RateLimit.Burst = 1
+	conf.RateLimit.MaxWait = 20
+	if err := conf.Check(); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClientWithRoundTripper("test-rate-limit-max-wait", conf, stdhttp.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+
+	ctx := WithoutZAppFilter(context.Background())
+	if _, err = c.Get(ctx, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err = c.Get(ctx, srv.URL); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+	if cost := time.Since(start); cost > 500*time.Millisecond {
+		t.Errorf("waited %v, want at most MaxWait", cost)
+	}
+}
+
+// 获取并发名额失败时归还已获取的令牌
+func TestAcquireLimitRefund(t *testing.T) {
+	conf := newConfig()
+	conf.RateLimit.QPS = 0.001
+	conf.RateLimit.Burst = 2
+	conf.RateLimit.HostMaxInflight = 1
+	conf.RateLimit.FailFast = true
+	if err := conf.Check(); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClientWithRoundTripper("test-limit-refund", conf, stdhttp.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	conn := c.(*cli).conn
+
+	release, err := acquireLimit(context.Background(), conn, "a.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// host的并发名额已用完, 客户端的令牌需要归还
+	if _, err = acquireLimit(context.Background(), conn, "a.test"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+	release()
+	release, err = acquireLimit(context.Background(), conn, "a.test")
+	if err != nil {
+		t.Fatalf("token is not refunded: %v", err)
+	}
+	release()
+}
+
+func TestEvictLimiters(t *testing.T) {
+	client := "test-evict-limiters"
+	t.Cleanup(func() { removeLimiters(client, 0) })
+	old := getLimiter(limiterKey{client: client, host: "old"}, 1, 1, 0)
+	busy := getLimiter(limiterKey{client: client, host: "busy"}, 0, 0, 1)
+	clientLevel := getLimiter(limiterKey{client: client}, 1, 1, 0)
+	if err := busy.acquire(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []*limiter{old, busy, clientLevel} {
+		l.lastUsed.Store(time.Now().Add(-2 * limiterIdleTimeout).UnixNano())
+	}
+	limitersEvictedAt.Store(0)
+
+	getLimiter(limiterKey{client: client, host: "new"}, 1, 1, 0)
+	tests := []struct {
+		key  limiterKey
+		want bool
+	}{
+		{key: limiterKey{client: client, host: "old"}, want: false},
+		{key: limiterKey{client: client, host: "busy"}, want: true},
+		{key: limiterKey{client: client}, want: true},
+		{key: limiterKey{client: client, host: "new"}, want: true},
+	}
+	for _, tt := range tests {
+		if _, ok := limiters.Load(tt.key); ok != tt.want {
+			t.Errorf("limiter %q exists = %v, want %v", tt.key.host, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"context"
+)
+
+// 发送请求的处理函数
+type Handler func(ctx context.Context, req *Request) (*Response, error)
+
+// 请求中间件, 可以在调用 next 前修改请求(签名, 注入token, 改写header等), 调用 next 后处理响应.
+//
+// 执行顺序: zapp filter -> 认证(配置了 Auth 时) -> 中间件(按 Use 的调用顺序, 先添加的在外层) -> 响应缓存 -> 发送请求.
+// 中间件在每次重试时都会执行, 使用 WithoutZAppFilter 时中间件依然会执行.
+// zapp filter 看到的是按 Redact 配置脱敏后的副本, 中间件看到的是实际的请求和响应.
+type Middleware func(next Handler) Handler
+
+// 添加中间件, 只对当前客户端生效
+func (c *cli) Use(mws ...Middleware) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	// 写时复制, 避免影响正在执行的请求
+	c.mws = append(c.mws[:len(c.mws):len(c.mws)], mws...)
+}
+
+// 经过中间件后发送请求
+func (c *cli) handle(ctx context.Context, conn *clientConn, r *Request) (*Response, error) {
+	c.mx.RLock()
+	mws := c.mws
+	c.mx.RUnlock()
+
+	h := func(ctx context.Context, r *Request) (*Response, error) {
+		return c._do(ctx, conn, r)
+	}
+	// 缓存在最内层, 命中缓存时不占用限流名额
+	if conn.cache != nil {
+		h = conn.cache.middleware(h)
+	}
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	// 认证在最外层, 以便中间件可以看到认证信息
+	if conn.auth != nil {
+		h = authMiddleware(conn.auth)(h)
+	}
+	return h(ctx, r)
+}
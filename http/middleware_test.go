@@ -0,0 +1,221 @@
+package http
+
+import (
+	"context"
+	"io"
+	stdhttp "net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type roundTripFunc func(req *stdhttp.Request) (*stdhttp.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *stdhttp.Request) (*stdhttp.Response, error) { return f(req) }
+
+// 记录执行顺序
+type callRecorder struct {
+	mx    sync.Mutex
+	calls []string
+}
+
+func (c *callRecorder) add(call string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.calls = append(c.calls, call)
+}
+
+func (c *callRecorder) take() []string {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	ret := c.calls
+	c.calls = nil
+	return ret
+}
+
+func (c *callRecorder) middleware(name string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			c.add(name + ">")
+			sp, err := next(ctx, r)
+			if sp != nil && sp.Cache != "" {
+				c.add("<" + name + " " + sp.Cache)
+			} else {
+				c.add("<" + name)
+			}
+			return sp, err
+		}
+	}
+}
+
+// 替换 zapp filter, 记录filter的执行
+func fakeClientFilter(t *testing.T, rec *callRecorder) {
+	old := handleClientFilter
+	handleClientFilter = func(ctx context.Context, name, method string, callersSkip int, header Header, req interface{},
+		next func(ctx context.Context, req interface{}) (interface{}, error)) (interface{}, error) {
+		rec.add("filter>")
+		rsp, err := next(ctx, req)
+		rec.add("<filter")
+		return rsp, err
+	}
+	t.Cleanup(func() { handleClientFilter = old })
+}
+
+// 记录认证的执行
+type recordAuth struct {
+	authenticator
+	rec *callRecorder
+}
+
+func (a *recordAuth) authorize(ctx context.Context, r *Request) error {
+	a.rec.add("auth")
+	return a.authenticator.authorize(ctx, r)
+}
+
+// 按顺序返回状态码的客户端, 可以缓存的响应
+func newOrderTestClient(t *testing.T, name string, rec *callRecorder, codes ...int) *cli {
+	conf := newConfig()
+	conf.Cache.Enable = true
+	if err := conf.Check(); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	rt := roundTripFunc(func(req *stdhttp.Request) (*stdhttp.Response, error) {
+		rec.add("send")
+		code := stdhttp.StatusOK
+		if n < len(codes) {
+			code = codes[n]
+		}
+		n++
+		header := stdhttp.Header{}
+		if code == stdhttp.StatusOK {
+			header.Set("Cache-Control", "max-age=60")
+		}
+		return &stdhttp.Response{
+			StatusCode:    code,
+			Status:        stdhttp.StatusText(code),
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader("ok")),
+			ContentLength: 2,
+			Request:       req,
+		}, nil
+	})
+	c, err := NewClientWithRoundTripper(name, conf, rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Use(rec.middleware("A"), rec.middleware("B"))
+	return c.(*cli)
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	tests := []struct {
+		name          string
+		withoutFilter bool
+		auth          bool  // 配置认证
+		codes         []int // 依次返回的状态码
+		retry         *RetryPolicy
+		requests      int
+		want          [][]string // 每个请求的执行顺序
+	}{
+		{
+			name:     "filter, middlewares, cache, send",
+			requests: 2,
+			want: [][]string{
+				{"filter>", "A>", "B>", "send", "<B MISS", "<A MISS", "<filter"},
+				{"filter>", "A>", "B>", "<B HIT", "<A HIT", "<filter"},
+			},
+		},
+		{
+			// 带有 Authorization 的请求不缓存
+			name:     "auth before middlewares",
+			auth:     true,
+			requests: 2,
+			want: [][]string{
+				{"filter>", "auth", "A>", "B>", "send", "<B", "<A", "<filter"},
+				{"filter>", "auth", "A>", "B>", "send", "<B", "<A", "<filter"},
+			},
+		},
+		{
+			name:     "filter and middlewares run on every retry",
+			codes:    []int{503, 502},
+			retry:    &RetryPolicy{MaxAttempts: 3},
+			requests: 1,
+			want: [][]string{{
+				"filter>", "A>", "B>", "send", "<B MISS", "<A MISS", "<filter",
+				"filter>", "A>", "B>", "send", "<B MISS", "<A MISS", "<filter",
+				"filter>", "A>", "B>", "send", "<B MISS", "<A MISS", "<filter",
+			}},
+		},
+		{
+			name:          "without zapp filter",
+			withoutFilter: true,
+			codes:         []int{503},
+			retry:         &RetryPolicy{MaxAttempts: 2},
+			requests:      2,
+			want: [][]string{
+				{"A>", "B>", "send", "<B MISS", "<A MISS", "A>", "B>", "send", "<B MISS", "<A MISS"},
+				{"A>", "B>", "<B HIT", "<A HIT"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &callRecorder{}
+			fakeClientFilter(t, rec)
+			c := newOrderTestClient(t, "test-order-"+strings.ReplaceAll(tt.name, " ", "-"), rec, tt.codes...)
+			if tt.auth {
+				c.conn.auth = &recordAuth{authenticator: staticAuth("Bearer token"), rec: rec}
+			}
+
+			ctx := context.Background()
+			if tt.withoutFilter {
+				ctx = WithoutZAppFilter(ctx)
+			}
+			for i := 0; i < tt.requests; i++ {
+				req := NewRequest(stdhttp.MethodGet, "http://order.test/a", "")
+				if tt.retry != nil {
+					req.applyOptions(WithRetry(*tt.retry))
+				}
+				if _, err := c.Do(ctx, req); err != nil {
+					t.Fatal(err)
+				}
+				if got := rec.take(); !reflect.DeepEqual(got, tt.want[i]) {
+					t.Errorf("request %d calls =\n%v\nwant\n%v", i+1, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// 中间件看到的是实际的请求, filter看到的是脱敏后的副本
+func TestMiddlewareSeesRealRequest(t *testing.T) {
+	rec := &callRecorder{}
+	var filterAuth, middlewareAuth string
+	old := handleClientFilter
+	handleClientFilter = func(ctx context.Context, name, method string, callersSkip int, header Header, req interface{},
+		next func(ctx context.Context, req interface{}) (interface{}, error)) (interface{}, error) {
+		filterAuth = req.(*Request).Header.Get("Authorization")
+		return next(ctx, req)
+	}
+	t.Cleanup(func() { handleClientFilter = old })
+
+	c := newOrderTestClient(t, "test-order-real-request", rec)
+	c.Use(func(next Handler) Handler {
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			middlewareAuth = r.Header.Get("Authorization")
+			return next(ctx, r)
+		}
+	})
+	_, err := c.Get(context.Background(), "http://order.test/a", WithInHeader(Header{"Authorization": {"Bearer token"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filterAuth != redactedValue {
+		t.Errorf("filter sees Authorization %q, want %q", filterAuth, redactedValue)
+	}
+	if middlewareAuth != "Bearer token" {
+		t.Errorf("middleware sees Authorization %q, want %q", middlewareAuth, "Bearer token")
+	}
+}
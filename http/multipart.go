@@ -0,0 +1,177 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// multipart/form-data 请求数据构建器
+type Multipart struct {
+	boundary string
+	parts    []multipartPart
+}
+
+type multipartPart struct {
+	fieldName   string
+	fileName    string
+	contentType string
+	value       string    // 普通字段的值
+	filePath    string    // 从磁盘读取的文件
+	reader      io.Reader // 流式数据, 只能读取一次
+}
+
+// 创建一个 multipart/form-data 请求数据构建器
+func NewMultipart() *Multipart {
+	buf := make([]byte, 30)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		panic(err)
+	}
+	return &Multipart{boundary: hex.EncodeToString(buf)}
+}
+
+// 添加普通字段
+func (m *Multipart) AddField(fieldName, value string) *Multipart {
+	m.parts = append(m.parts, multipartPart{fieldName: fieldName, value: value})
+	return m
+}
+
+// 添加磁盘上的文件, 文件在发送时才会打开. 文件名使用 filePath 的 base 部分, 内容类型根据扩展名推断
+func (m *Multipart) AddFile(fieldName, filePath string) *Multipart {
+	m.parts = append(m.parts, multipartPart{
+		fieldName:   fieldName,
+		fileName:    filepath.Base(filePath),
+		contentType: mime.TypeByExtension(filepath.Ext(filePath)),
+		filePath:    filePath,
+	})
+	return m
+}
+
+// 添加流式数据作为文件, contentType 为空时使用 application/octet-stream. 包含流式数据的请求不会重试
+func (m *Multipart) AddReader(fieldName, fileName, contentType string, r io.Reader) *Multipart {
+	m.parts = append(m.parts, multipartPart{
+		fieldName:   fieldName,
+		fileName:    fileName,
+		contentType: contentType,
+		reader:      r,
+	})
+	return m
+}
+
+// 获取 Content-Type
+func (m *Multipart) ContentType() string {
+	return "multipart/form-data; boundary=" + m.boundary
+}
+
+// 是否可以重放, 包含流式数据时不能重放
+func (m *Multipart) replayable() bool {
+	for _, p := range m.parts {
+		if p.reader != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// 打开body, 数据在被读取时才会通过管道边读边写
+func (m *Multipart) open() io.ReadCloser {
+	return &lazyPipeReader{write: m.writeTo}
+}
+
+func (m *Multipart) writeTo(w io.Writer) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(m.boundary); err != nil {
+		return err
+	}
+	for _, p := range m.parts {
+		if err := p.writeTo(mw); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func (p *multipartPart) writeTo(mw *multipart.Writer) error {
+	if p.filePath == "" && p.reader == nil {
+		return mw.WriteField(p.fieldName, p.value)
+	}
+
+	contentType := p.contentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(p.fieldName), quoteEscaper.Replace(p.fileName)))
+	h.Set("Content-Type", contentType)
+	w, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+
+	if p.reader != nil {
+		_, err = io.Copy(w, p.reader)
+		return err
+	}
+
+	f, err := os.Open(p.filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// 首次读取时才启动写入协程的管道, 未被读取时不会产生协程
+type lazyPipeReader struct {
+	write func(w io.Writer) error
+
+	mx     sync.Mutex
+	pr     *io.PipeReader
+	closed bool
+}
+
+func (l *lazyPipeReader) reader() (*io.PipeReader, error) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	if l.closed {
+		return nil, io.ErrClosedPipe
+	}
+	if l.pr == nil {
+		pr, pw := io.Pipe()
+		l.pr = pr
+		go func() {
+			_ = pw.CloseWithError(l.write(pw))
+		}()
+	}
+	return l.pr, nil
+}
+
+func (l *lazyPipeReader) Read(p []byte) (int, error) {
+	pr, err := l.reader()
+	if err != nil {
+		return 0, err
+	}
+	return pr.Read(p)
+}
+
+func (l *lazyPipeReader) Close() error {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.closed = true
+	if l.pr == nil {
+		return nil
+	}
+	return l.pr.Close()
+}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"context"
 	"io"
 	"net/url"
 	"time"
@@ -44,17 +45,35 @@ func WithInParams(params url.Values) Option {
 	}
 }
 
-// 设置请求的json数据
-func WithInJson(inPtr interface{}) Option {
+// 使用编解码器设置请求数据, 会自动设置 Content-Type
+func WithInBody(codec string, inPtr interface{}) Option {
 	return func(r *Request) {
-		r.inJsonPtr = inPtr
+		r.inCodec = codec
+		r.inPtr = inPtr
 	}
 }
 
+// 设置请求的json数据
+func WithInJson(inPtr interface{}) Option {
+	return WithInBody(CodecJson, inPtr)
+}
+
 // 设置请求的yaml数据
 func WithInYaml(inPtr interface{}) Option {
+	return WithInBody(CodecYaml, inPtr)
+}
+
+// 设置请求的表单数据, 会自动设置 Content-Type 为 application/x-www-form-urlencoded
+func WithInForm(form url.Values) Option {
+	return WithInBody(CodecForm, form)
+}
+
+// 设置请求的 multipart/form-data 数据, 会自动设置 Content-Type
+//
+//	http.WithInMultipart(http.NewMultipart().AddField("name", "zly").AddFile("file", "./a.png"))
+func WithInMultipart(m *Multipart) Option {
 	return func(r *Request) {
-		r.inYamlPtr = inPtr
+		r.inMultipart = m
 	}
 }
 
@@ -65,18 +84,23 @@ func WithInBodyStream(body io.Reader) Option {
 	}
 }
 
-// 数据解析为json, 如果标记响应body是流数据, 不需要调用 BodyStream.Close()
-func WithOutJson(outPtr interface{}) Option {
+// 使用编解码器解析响应数据, 会自动设置 Accept. codec 为 CodecAuto 时根据响应的 Content-Type 选择编解码器.
+// 如果标记响应body是流数据, 不需要调用 BodyStream.Close()
+func WithOutBody(codec string, outPtr interface{}) Option {
 	return func(r *Request) {
-		r.outJsonPtr = outPtr
+		r.outCodec = codec
+		r.outPtr = outPtr
 	}
 }
 
+// 数据解析为json, 如果标记响应body是流数据, 不需要调用 BodyStream.Close()
+func WithOutJson(outPtr interface{}) Option {
+	return WithOutBody(CodecJson, outPtr)
+}
+
 // 数据解析为yaml, 如果标记响应body是流数据, 不需要调用 BodyStream.Close()
 func WithOutYaml(outPtr interface{}) Option {
-	return func(r *Request) {
-		r.outYamlPtr = outPtr
-	}
+	return WithOutBody(CodecYaml, outPtr)
 }
 
 // 标记响应body是流数据, 读取方式从Body改为BodyStream, 且读取完毕后需要调用 BodyStream.Close()
@@ -99,3 +123,126 @@ func WithProxy(proxy string) Option {
 		r.Proxy = proxy
 	}
 }
+
+// 设置重试策略, 会覆盖客户端配置的默认重试策略. 传入零值 RetryPolicy{} 表示本次请求不重试
+func WithRetry(policy RetryPolicy) Option {
+	return func(r *Request) {
+		r.retry = &policy
+	}
+}
+
+// 设置客户端证书文件, 用于mTLS
+func WithClientCertFile(certFile, keyFile string) Option {
+	return func(r *Request) {
+		r.tls.CertFile, r.tls.KeyFile = certFile, keyFile
+		r.tls.CertPEM, r.tls.KeyPEM = "", ""
+	}
+}
+
+// 设置客户端证书内容(pem), 用于mTLS
+func WithClientCertPEM(certPEM, keyPEM []byte) Option {
+	return func(r *Request) {
+		r.tls.CertPEM, r.tls.KeyPEM = string(certPEM), string(keyPEM)
+		r.tls.CertFile, r.tls.KeyFile = "", ""
+	}
+}
+
+// 设置自定义ca证书文件
+func WithCAFile(caFile string) Option {
+	return func(r *Request) {
+		r.tls.CAFile = caFile
+	}
+}
+
+// 设置自定义ca证书内容(pem)
+func WithCAPEM(caPEM []byte) Option {
+	return func(r *Request) {
+		r.tls.CAPEM = string(caPEM)
+	}
+}
+
+// 覆盖用于校验证书的服务器名
+func WithServerName(serverName string) Option {
+	return func(r *Request) {
+		r.tls.ServerName = serverName
+	}
+}
+
+// 设置最小tls版本, 如 tls.VersionTLS12
+func WithMinTLSVersion(version uint16) Option {
+	return func(r *Request) {
+		r.tls.MinVersion = version
+	}
+}
+
+// 设置完整的tls配置, 非零值的字段会覆盖客户端的tls配置
+func WithTLSConfig(conf TLSConfig) Option {
+	return func(r *Request) {
+		r.tls = conf
+	}
+}
+
+// 设置期望的响应状态码, 不符合时返回 *StatusError 且不再解析响应数据. 不传入状态码表示期望 2xx
+func WithExpectStatus(codes ...int) Option {
+	return func(r *Request) {
+		r.status = &statusPolicy{enable: true, codes: codes}
+	}
+}
+
+// 响应状态码不符合预期时将响应数据按json解析到 outPtr, 同时返回 *StatusError.
+// 未设置期望的状态码时期望 2xx
+func WithOutErrorJson(outPtr interface{}) Option {
+	return func(r *Request) {
+		r.outErrorPtr = outPtr
+	}
+}
+
+// 设置响应body最大字节数, 超过时返回 ErrResponseTooLarge
+func WithMaxResponseBytes(n int64) Option {
+	return func(r *Request) {
+		r.MaxResponseBytes = n
+	}
+}
+
+// 设置下载进度回调, 仅用于 Download. written 为已写入的字节数(包含续传前已下载的部分), total 未知时为 -1
+func WithDownloadProgress(fn func(written, total int64)) Option {
+	return func(r *Request) {
+		r.download.progress = fn
+	}
+}
+
+// 设置下载文件的校验和, 仅用于 Download. algo 支持 md5, sha1, sha256, sha512, sum 为16进制字符串
+func WithDownloadChecksum(algo, sum string) Option {
+	return func(r *Request) {
+		r.download.checksumAlgo = algo
+		r.download.checksum = sum
+	}
+}
+
+// 按 Server-Sent Events 格式逐个解析响应事件, 读取完毕后自动关闭body. 会自动设置 Accept 为 text/event-stream.
+// handler 收到的 ctx 与请求经过 zapp filter 和 trace 后的 ctx 相同, handler 返回 ErrStopStream 时停止读取且不会返回错误.
+// 仅在响应状态码为 2xx 时解析, 否则响应数据会读取到 Response.Body
+func WithOutSSE(handler func(ctx context.Context, event *SSEEvent) error) Option {
+	return func(r *Request) {
+		r.outAccept = "text/event-stream"
+		r.outStream = func(ctx context.Context, body io.Reader) error {
+			return readSSE(ctx, body, handler)
+		}
+	}
+}
+
+// 按 ndjson 格式逐行解析响应数据, 每行使用 newPtr 创建的变量解析后调用 handler, 读取完毕后自动关闭body.
+// 会自动设置 Accept 为 application/x-ndjson, 其它行为和 WithOutSSE 相同
+//
+//	http.WithOutNDJSON(func() interface{} { return new(Item) }, func(ctx context.Context, v interface{}) error {
+//		item := v.(*Item)
+//		return nil
+//	})
+func WithOutNDJSON(newPtr func() interface{}, handler func(ctx context.Context, v interface{}) error) Option {
+	return func(r *Request) {
+		r.outAccept = "application/x-ndjson"
+		r.outStream = func(ctx context.Context, body io.Reader) error {
+			return readNDJSON(ctx, body, newPtr, handler)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+// 分页方式
+const (
+	PaginatePage   = "page"   // 使用页码和每页数量参数
+	PaginateCursor = "cursor" // 使用响应json中的游标
+	PaginateLink   = "link"   // 使用响应头 Link 中 rel="next" 的地址
+)
+
+// 分页配置
+type PaginateConfig struct {
+	Style string // 分页方式, 支持 page, cursor, link, 默认 page
+
+	ItemsField string // 响应json中数据列表的字段路径, 以.分隔, 如 data.items, 为空表示响应本身是数组
+	MaxPages   int    // 最多请求的页数, 0表示不限制
+
+	PageParam string // page 的页码参数名, 默认 page
+	SizeParam string // page 的每页数量参数名, 默认 size
+	StartPage int    // page 的起始页码, 默认 1
+	PageSize  int    // page 的每页数量, 0表示不设置每页数量参数. 返回的数量少于它时结束
+
+	CursorParam string // cursor 的请求参数名, 默认 cursor
+	CursorField string // cursor 的响应json中下一页游标的字段路径, 以.分隔, 如 meta.next_cursor, 为空或null时结束
+}
+
+func (p *PaginateConfig) check() error {
+	switch p.Style {
+	case "":
+		p.Style = PaginatePage
+	case PaginatePage, PaginateLink:
+	case PaginateCursor:
+		if p.CursorField == "" {
+			return fmt.Errorf("cursor 分页的 CursorField 为空")
+		}
+	default:
+		return fmt.Errorf("不支持的分页方式: %s", p.Style)
+	}
+	if p.PageParam == "" {
+		p.PageParam = "page"
+	}
+	if p.SizeParam == "" {
+		p.SizeParam = "size"
+	}
+	if p.StartPage == 0 {
+		p.StartPage = 1
+	}
+	if p.CursorParam == "" {
+		p.CursorParam = "cursor"
+	}
+	return nil
+}
+
+// 使用Get请求逐页获取数据, 将每一项按json解析为 T 后返回. 响应状态码不是 2xx 时返回 *StatusError.
+// 出错时会返回一次错误然后结束, ctx 取消时同样会返回错误
+//
+//	for user, err := range http.Paginate[User](ctx, c, "/users", http.PaginateConfig{ItemsField: "data", PageSize: 100}) {
+//		if err != nil {
+//			return err
+//		}
+//	}
+func Paginate[T any](ctx context.Context, c Client, path string, conf PaginateConfig, opts ...Option) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		if err := conf.check(); err != nil {
+			yield(zero, err)
+			return
+		}
+
+		page, cursor, nextPath := conf.StartPage, "", path
+		for n := 0; conf.MaxPages <= 0 || n < conf.MaxPages; n++ {
+			if err := ctx.Err(); err != nil {
+				yield(zero, err)
+				return
+			}
+
+			req := NewRequest(http.MethodGet, nextPath, "")
+			req.applyOptions(WithExpectStatus())
+			req.applyOptions(opts...)
+			if req.Params == nil {
+				req.Params = make(Values)
+			} else {
+				req.Params = cloneValues(req.Params)
+			}
+			switch conf.Style {
+			case PaginatePage:
+				req.Params.Set(conf.PageParam, strconv.Itoa(page))
+				if conf.PageSize > 0 {
+					req.Params.Set(conf.SizeParam, strconv.Itoa(conf.PageSize))
+				}
+			case PaginateCursor:
+				if cursor != "" {
+					req.Params.Set(conf.CursorParam, cursor)
+				}
+			case PaginateLink:
+				if n > 0 {
+					req.Params = nil // 下一页的地址已经包含了参数
+				}
+			}
+
+			sp, err := c.Do(ctx, req)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			items, err := jsonField[[]T](sp.Body, conf.ItemsField)
+			if err != nil {
+				yield(zero, fmt.Errorf("解析分页数据失败: %v", err))
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+
+			switch conf.Style {
+			case PaginatePage:
+				if len(items) == 0 || (conf.PageSize > 0 && len(items) < conf.PageSize) {
+					return
+				}
+				page++
+			case PaginateCursor:
+				next, err := jsonField[*json.RawMessage](sp.Body, conf.CursorField)
+				if err != nil {
+					yield(zero, fmt.Errorf("解析分页游标失败: %v", err))
+					return
+				}
+				if cursor = rawToString(next); cursor == "" {
+					return
+				}
+			case PaginateLink:
+				next := nextLink(sp.Header)
+				if next == "" {
+					return
+				}
+				nextPath = resolveLink(nextPath, next)
+			}
+		}
+	}
+}
+
+func cloneValues(v Values) Values {
+	ret := make(Values, len(v))
+	for k, vv := range v {
+		ret[k] = append([]string(nil), vv...)
+	}
+	return ret
+}
+
+// 按字段路径解析json中的数据, 路径为空表示整个json, 字段不存在时返回零值
+func jsonField[T any](body string, path string) (T, error) {
+	var ret T
+	data := json.RawMessage(body)
+	if path != "" {
+		for _, field := range strings.Split(path, ".") {
+			var m map[string]json.RawMessage
+			if err := sonic.Unmarshal(data, &m); err != nil {
+				return ret, err
+			}
+			v, ok := m[field]
+			if !ok {
+				return ret, nil
+			}
+			data = v
+		}
+	}
+	if len(data) == 0 {
+		return ret, nil
+	}
+	err := sonic.Unmarshal(data, &ret)
+	return ret, err
+}
+
+// 将json中的游标转为字符串, 支持字符串和数字, null 返回空字符串
+func rawToString(raw *json.RawMessage) string {
+	if raw == nil {
+		return ""
+	}
+	var s string
+	if err := sonic.Unmarshal(*raw, &s); err == nil {
+		return s
+	}
+	v := strings.TrimSpace(string(*raw))
+	if v == "null" {
+		return ""
+	}
+	return v
+}
+
+// 解析 rfc5988 Link 头中 rel="next" 的地址
+func nextLink(header Header) string {
+	for _, v := range header.Values("Link") {
+		for _, link := range strings.Split(v, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				k, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(strings.TrimSpace(k), "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(val, `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// 解析相对地址, 当前地址不是完整url时直接使用下一页的地址
+func resolveLink(current, next string) string {
+	nu, err := url.Parse(next)
+	if err != nil || nu.IsAbs() {
+		return next
+	}
+	cu, err := url.Parse(current)
+	if err != nil || !cu.IsAbs() {
+		return next
+	}
+	return cu.ResolveReference(nu).String()
+}
@@ -0,0 +1,269 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// 脱敏后的值
+const redactedValue = "[REDACTED]"
+
+// 默认需要脱敏的header
+var defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// 默认的脱敏策略, 只对默认的header脱敏
+var defaultRedactPolicy, _ = NewRedactPolicy(RedactConfig{})
+
+// 脱敏策略, 作用于 zapp filter(日志, 链路追踪等)看到的请求和响应副本, 实际发送和返回给调用者的数据不会被脱敏. filter对副本的修改会同步到实际的请求和响应, 包含脱敏值的header和被脱敏的body除外
+type RedactPolicy struct {
+	headers      []string
+	fields       [][]string
+	patterns     []*regexp.Regexp
+	maxBodyBytes int64
+}
+
+// 创建脱敏策略, conf.Headers 为nil时使用默认的header
+func NewRedactPolicy(conf RedactConfig) (*RedactPolicy, error) {
+	p := &RedactPolicy{headers: conf.Headers, maxBodyBytes: conf.MaxBodyBytes}
+	if p.headers == nil {
+		p.headers = defaultRedactHeaders
+	}
+	for _, field := range conf.JsonFields {
+		if field == "" {
+			continue
+		}
+		p.fields = append(p.fields, strings.Split(field, "."))
+	}
+	for _, pattern := range conf.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("脱敏正则 %q 无效: %v", pattern, err)
+		}
+		p.patterns = append(p.patterns, re)
+	}
+	return p, nil
+}
+
+// 返回脱敏后的header副本
+func (p *RedactPolicy) Header(header Header) Header {
+	if header == nil {
+		return nil
+	}
+	ret := make(Header, len(header))
+	for k, v := range header {
+		if matchHeader(p.headers, k) {
+			vv := make([]string, len(v))
+			for i := range vv {
+				vv[i] = redactedValue
+			}
+			ret[k] = vv
+		} else {
+			ret[k] = append([]string(nil), v...)
+		}
+	}
+	return ret
+}
+
+// 返回脱敏后的body, 依次处理json字段和正则, 最后按最大长度截断. 第二个返回值表示是否被截断
+func (p *RedactPolicy) Body(body string) (string, bool) {
+	if body == "" {
+		return body, false
+	}
+	if p.maxBodyBytes < 0 {
+		return "", true
+	}
+	if len(p.fields) > 0 {
+		body = p.redactJson(body)
+	}
+	for _, re := range p.patterns {
+		body = re.ReplaceAllString(body, redactedValue)
+	}
+	if p.maxBodyBytes > 0 && int64(len(body)) > p.maxBodyBytes {
+		return body[:p.maxBodyBytes], true
+	}
+	return body, false
+}
+
+// 对json中匹配的字段脱敏, 不是json或没有匹配的字段时返回原数据
+func (p *RedactPolicy) redactJson(body string) string {
+	trimmed := strings.TrimSpace(body)
+	if trimmed == "" || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return body
+	}
+	dec := json.NewDecoder(strings.NewReader(trimmed))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return body
+	}
+
+	changed := false
+	for _, path := range p.fields {
+		if len(path) == 1 {
+			changed = redactJsonAnyDepth(v, path[0]) || changed
+		} else {
+			changed = redactJsonPath(v, path) || changed
+		}
+	}
+	if !changed {
+		return body
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return body
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// 按路径脱敏, * 匹配任意字段, 数组会对每个元素应用剩余的路径
+func redactJsonPath(v interface{}, path []string) bool {
+	switch vv := v.(type) {
+	case []interface{}:
+		changed := false
+		for _, item := range vv {
+			changed = redactJsonPath(item, path) || changed
+		}
+		return changed
+	case map[string]interface{}:
+		changed := false
+		for k, item := range vv {
+			if path[0] != "*" && path[0] != k {
+				continue
+			}
+			if len(path) == 1 {
+				vv[k] = redactedValue
+				changed = true
+			} else {
+				changed = redactJsonPath(item, path[1:]) || changed
+			}
+		}
+		return changed
+	}
+	return false
+}
+
+// 对任意层级的同名字段脱敏
+func redactJsonAnyDepth(v interface{}, field string) bool {
+	changed := false
+	switch vv := v.(type) {
+	case []interface{}:
+		for _, item := range vv {
+			changed = redactJsonAnyDepth(item, field) || changed
+		}
+	case map[string]interface{}:
+		for k, item := range vv {
+			if k == field {
+				vv[k] = redactedValue
+				changed = true
+			} else {
+				changed = redactJsonAnyDepth(item, field) || changed
+			}
+		}
+	}
+	return changed
+}
+
+// 返回提供给filter的请求, 脱敏的header和body是副本
+func (p *RedactPolicy) request(r *Request) *Request {
+	v := *r
+	v.Header = p.Header(r.Header)
+	v.Body, _ = p.Body(r.Body)
+	return &v
+}
+
+// 返回提供给filter的响应, 脱敏的header和body是副本
+func (p *RedactPolicy) response(sp *Response) *Response {
+	v := *sp
+	v.Header = p.Header(sp.Header)
+	v.Body, _ = p.Body(sp.Body)
+	return &v
+}
+
+// 返回filter处理前的请求副本, 用于比较filter的修改
+func snapshotRequest(v *Request) *Request {
+	b := *v
+	b.Header = v.Header.Clone()
+	b.Params = cloneValues(v.Params)
+	return &b
+}
+
+// 返回filter处理前的响应副本, 用于比较filter的修改
+func snapshotResponse(v *Response) *Response {
+	b := *v
+	b.Header = v.Header.Clone()
+	return &b
+}
+
+// 将filter对请求副本的修改同步到实际请求, base 为filter处理前的副本. 包含脱敏值的header和被脱敏的body不会同步
+func mergeRequest(dst, base, view *Request) {
+	if view.Method != base.Method {
+		dst.Method = view.Method
+	}
+	if view.Path != base.Path {
+		dst.Path = view.Path
+	}
+	if view.Params.Encode() != base.Params.Encode() {
+		dst.Params = view.Params
+	}
+	if dst.Header == nil {
+		dst.Header = make(Header)
+	}
+	syncHeader(dst.Header, base.Header, view.Header)
+	if view.Body != base.Body && base.Body == dst.Body {
+		dst.Body = view.Body
+	}
+}
+
+// 将filter对响应副本的修改同步到实际响应, base 为filter处理前的副本. 包含脱敏值的header和被脱敏的body不会同步
+func mergeResponse(dst, base, view *Response) {
+	if view.StatusCode != base.StatusCode {
+		dst.StatusCode = view.StatusCode
+	}
+	if view.Status != base.Status {
+		dst.Status = view.Status
+	}
+	if dst.Header == nil {
+		dst.Header = make(Header)
+	}
+	syncHeader(dst.Header, base.Header, view.Header)
+	if view.Body != base.Body && base.Body == dst.Body {
+		dst.Body = view.Body
+		dst.ContentLength = int64(len(view.Body))
+	}
+}
+
+// 将filter在header副本上的修改同步到实际的header, base 为filter处理前的副本. 值包含脱敏值的header不会同步, filter删除的header会同步删除
+func syncHeader(dst, base, view Header) {
+	for k, v := range view {
+		if slices.Equal(v, base[k]) || slices.Contains(v, redactedValue) {
+			continue
+		}
+		dst[k] = append([]string(nil), v...)
+	}
+	for k := range base {
+		if _, ok := view[k]; !ok {
+			delete(dst, k)
+		}
+	}
+}
+
+// 判断header是否匹配规则, 规则不区分大小写, 以*结尾时按前缀匹配
+func matchHeader(rules []string, name string) bool {
+	for _, rule := range rules {
+		if prefix, ok := strings.CutSuffix(rule, "*"); ok {
+			if len(name) >= len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+				return true
+			}
+		} else if strings.EqualFold(name, rule) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"errors"
+	"io"
+)
+
+// 响应body超过最大字节数时返回这个错误
+var ErrResponseTooLarge = errors.New("http: response body too large")
+
+// 读取全部数据, 超过 maxBytes 时返回 ErrResponseTooLarge, maxBytes 小于1表示不限制
+func readAllLimit(r io.Reader, maxBytes int64) ([]byte, error) {
+	if maxBytes < 1 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, ErrResponseTooLarge
+	}
+	return data, nil
+}
+
+// 读取超过最大字节数时返回 ErrResponseTooLarge
+type limitReadCloser struct {
+	rc     io.ReadCloser
+	remain int64
+}
+
+func newLimitReadCloser(rc io.ReadCloser, maxBytes int64) io.ReadCloser {
+	if maxBytes < 1 {
+		return rc
+	}
+	return &limitReadCloser{rc: rc, remain: maxBytes}
+}
+
+func (l *limitReadCloser) Read(p []byte) (int, error) {
+	if l.remain < 0 {
+		return 0, ErrResponseTooLarge
+	}
+	// 多读一个字节用于判断是否超过限制
+	if int64(len(p)) > l.remain+1 {
+		p = p[:l.remain+1]
+	}
+	n, err := l.rc.Read(p)
+	l.remain -= int64(n)
+	if l.remain < 0 {
+		return n + int(l.remain), ErrResponseTooLarge
+	}
+	return n, err
+}
+
+func (l *limitReadCloser) Close() error {
+	return l.rc.Close()
+}
@@ -0,0 +1,199 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// 默认需要重试的响应状态码
+var defaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// 重试策略
+type RetryPolicy struct {
+	MaxAttempts        int           // 最大尝试次数(包含首次请求), 小于2表示不重试
+	StatusCodes        []int         // 需要重试的响应状态码, 为空时使用 429,502,503,504
+	MinBackoff         time.Duration // 首次重试前的等待时间, 之后每次翻倍, 实际等待时间会在 [backoff/2, backoff] 之间随机
+	MaxBackoff         time.Duration // 最大等待时间
+	MaxRetryAfter      time.Duration // 响应头 Retry-After 要求的等待时间超过该值时不再重试, 0表示不限制
+	AllowNonIdempotent bool          // 允许重试非幂等的方法(POST, PATCH)
+}
+
+func newRetryPolicy(conf *RetryConfig) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:        conf.MaxAttempts,
+		StatusCodes:        conf.StatusCodes,
+		MinBackoff:         time.Duration(conf.MinBackoff) * time.Millisecond,
+		MaxBackoff:         time.Duration(conf.MaxBackoff) * time.Millisecond,
+		MaxRetryAfter:      time.Duration(conf.MaxRetryAfter) * time.Millisecond,
+		AllowNonIdempotent: conf.AllowNonIdempotent,
+	}
+}
+
+// 是否为需要重试的状态码
+func (p *RetryPolicy) retryableStatus(code int) bool {
+	if len(p.StatusCodes) == 0 {
+		return slices.Contains(defaultRetryStatusCodes, code)
+	}
+	return slices.Contains(p.StatusCodes, code)
+}
+
+// 第n次重试前的等待时间, n从1开始
+func (p *RetryPolicy) backoff(n int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < n && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// 是否为幂等方法
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// 是否为可以重试的错误, 只有连接相关的错误才会重试
+func isRetryableErr(err error) bool {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(urlErr.Err, &netErr) && netErr.Timeout()
+}
+
+// 解析响应头 Retry-After, 支持秒数和http时间两种格式
+func parseRetryAfter(header Header) (time.Duration, bool) {
+	v := header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if sec, err := strconv.Atoi(v); err == nil {
+		if sec < 0 {
+			return 0, false
+		}
+		return time.Duration(sec) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// 只暴露 io.Reader, 避免标准库在发送后关闭可重放的body
+type readerOnly struct {
+	io.Reader
+}
+
+// 按重试策略发送请求
+func (c *cli) sendWithRetry(ctx context.Context, conn *clientConn, r *Request) (*Response, error) {
+	policy := r.retry
+	if policy == nil {
+		policy = conn.retry
+	}
+	attempts := policy.MaxAttempts
+	if !policy.AllowNonIdempotent && !isIdempotentMethod(r.Method) {
+		attempts = 1
+	}
+
+	if r.inMultipart != nil && !r.inMultipart.replayable() {
+		attempts = 1
+	}
+
+	// 流数据只有可以seek时才能重放
+	var seeker io.Seeker
+	var offset int64
+	if attempts > 1 && r.inStream != nil {
+		s, ok := r.inStream.(io.Seeker)
+		if ok {
+			var err error
+			offset, err = s.Seek(0, io.SeekCurrent)
+			ok = err == nil
+		}
+		if ok {
+			seeker = s
+			r.inStream = readerOnly{r.inStream}
+		} else {
+			attempts = 1
+		}
+	}
+
+	for n := 1; ; n++ {
+		sp, err := c.sendBalanced(ctx, conn, r)
+		if n >= attempts || ctx.Err() != nil {
+			return sp, err
+		}
+
+		var wait time.Duration
+		if err != nil {
+			if !isRetryableErr(err) {
+				return nil, err
+			}
+			wait = policy.backoff(n)
+		} else {
+			if !policy.retryableStatus(sp.StatusCode) {
+				return sp, nil
+			}
+			wait = policy.backoff(n)
+			if retryAfter, ok := parseRetryAfter(sp.Header); ok {
+				if policy.MaxRetryAfter > 0 && retryAfter > policy.MaxRetryAfter {
+					return sp, nil
+				}
+				wait = retryAfter
+			}
+		}
+
+		// 等待时间超过ctx的截止时间则不再重试
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return sp, err
+		}
+		if sp != nil && sp.BodyStream != nil {
+			_ = sp.BodyStream.Close()
+		}
+		if seeker != nil {
+			if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
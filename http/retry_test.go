@@ -0,0 +1,261 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 按顺序返回状态码的测试服务, 记录每次收到的请求body
+type retryServer struct {
+	*httptest.Server
+	mx     sync.Mutex
+	codes  []int
+	header stdhttp.Header // 失败响应附加的header
+	bodies []string
+}
+
+func newRetryServer(t *testing.T, codes ...int) *retryServer {
+	s := &retryServer{codes: codes, header: make(stdhttp.Header)}
+	s.Server = httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mx.Lock()
+		n := len(s.bodies)
+		s.bodies = append(s.bodies, string(body))
+		s.mx.Unlock()
+
+		code := stdhttp.StatusOK
+		if n < len(s.codes) {
+			code = s.codes[n]
+		}
+		if code != stdhttp.StatusOK {
+			for k, v := range s.header {
+				w.Header()[k] = v
+			}
+		}
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *retryServer) hits() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return len(s.bodies)
+}
+
+func newRetryTestClient(t *testing.T) Client {
+	conf := newConfig()
+	if err := conf.Check(); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClientWithRoundTripper("test-retry", conf, stdhttp.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := &RetryPolicy{MinBackoff: 10 * time.Millisecond, MaxBackoff: 40 * time.Millisecond}
+	tests := []struct {
+		n        int
+		min, max time.Duration
+	}{
+		{n: 1, min: 5 * time.Millisecond, max: 10 * time.Millisecond},
+		{n: 2, min: 10 * time.Millisecond, max: 20 * time.Millisecond},
+		{n: 3, min: 20 * time.Millisecond, max: 40 * time.Millisecond},
+		{n: 10, min: 20 * time.Millisecond, max: 40 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if d := p.backoff(tt.n); d < tt.min || d > tt.max {
+				t.Fatalf("backoff(%d) = %v, want in [%v, %v]", tt.n, d, tt.min, tt.max)
+			}
+		}
+	}
+	if d := (&RetryPolicy{}).backoff(1); d != 0 {
+		t.Errorf("backoff without MinBackoff = %v, want 0", d)
+	}
+}
+
+func TestRetryStatus(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	tests := []struct {
+		name     string
+		method   string
+		policy   RetryPolicy
+		codes    []int
+		wantCode int
+		wantHits int
+	}{
+		{name: "retry until success", method: "GET", policy: policy, codes: []int{503, 502}, wantCode: 200, wantHits: 3},
+		{name: "max attempts", method: "GET", policy: policy, codes: []int{503, 503, 503, 503}, wantCode: 503, wantHits: 3},
+		{name: "not retryable status", method: "GET", policy: policy, codes: []int{500}, wantCode: 500, wantHits: 1},
+		{name: "custom status codes", method: "GET", policy: RetryPolicy{MaxAttempts: 2, StatusCodes: []int{500}}, codes: []int{500}, wantCode: 200, wantHits: 2},
+		{name: "put is idempotent", method: "PUT", policy: policy, codes: []int{503}, wantCode: 200, wantHits: 2},
+		{name: "post is not retried", method: "POST", policy: policy, codes: []int{503}, wantCode: 503, wantHits: 1},
+		{name: "patch is not retried", method: "PATCH", policy: policy, codes: []int{503}, wantCode: 503, wantHits: 1},
+		{
+			name:     "allow non idempotent",
+			method:   "POST",
+			policy:   RetryPolicy{MaxAttempts: 3, AllowNonIdempotent: true},
+			codes:    []int{503},
+			wantCode: 200,
+			wantHits: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newRetryServer(t, tt.codes...)
+			c := newRetryTestClient(t)
+			req := NewRequest(tt.method, srv.URL, "body")
+			req.applyOptions(WithRetry(tt.policy))
+			sp, err := c.Do(WithoutZAppFilter(context.Background()), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sp.StatusCode != tt.wantCode {
+				t.Errorf("status = %d, want %d", sp.StatusCode, tt.wantCode)
+			}
+			if got := srv.hits(); got != tt.wantHits {
+				t.Errorf("hits = %d, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	ctx := WithoutZAppFilter(context.Background())
+
+	t.Run("retry after overrides backoff", func(t *testing.T) {
+		srv := newRetryServer(t, 429)
+		srv.header.Set("Retry-After", "0")
+		c := newRetryTestClient(t)
+		start := time.Now()
+		// 退避时间至少5s, 使用 Retry-After 时立即重试
+		sp, err := c.Get(ctx, srv.URL, WithRetry(RetryPolicy{MaxAttempts: 2, MinBackoff: 10 * time.Second, MaxBackoff: 10 * time.Second}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sp.StatusCode != 200 || srv.hits() != 2 {
+			t.Fatalf("status = %d, hits = %d, want 200 and 2", sp.StatusCode, srv.hits())
+		}
+		if cost := time.Since(start); cost > 2*time.Second {
+			t.Errorf("retried after %v, want Retry-After 0", cost)
+		}
+	})
+
+	t.Run("exceed max retry after", func(t *testing.T) {
+		srv := newRetryServer(t, 429)
+		srv.header.Set("Retry-After", "60")
+		c := newRetryTestClient(t)
+		sp, err := c.Get(ctx, srv.URL, WithRetry(RetryPolicy{MaxAttempts: 2, MaxRetryAfter: time.Second}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sp.StatusCode != 429 || srv.hits() != 1 {
+			t.Errorf("status = %d, hits = %d, want 429 and 1", sp.StatusCode, srv.hits())
+		}
+	})
+
+	t.Run("exceed ctx deadline", func(t *testing.T) {
+		srv := newRetryServer(t, 503)
+		srv.header.Set("Retry-After", "60")
+		c := newRetryTestClient(t)
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		sp, err := c.Get(ctx, srv.URL, WithRetry(RetryPolicy{MaxAttempts: 2}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sp.StatusCode != 503 || srv.hits() != 1 {
+			t.Errorf("status = %d, hits = %d, want 503 and 1", sp.StatusCode, srv.hits())
+		}
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{value: "", ok: false},
+		{value: "3", want: 3 * time.Second, ok: true},
+		{value: "-1", ok: false},
+		{value: "Wed, 21 Oct 2015 07:28:00 GMT", want: 0, ok: true},
+		{value: "soon", ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(Header{"Retry-After": {tt.value}})
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRetryReplayBody(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, AllowNonIdempotent: true}
+	tests := []struct {
+		name     string
+		opts     func() []Option
+		body     string
+		wantBody string
+		wantHits int
+	}{
+		{name: "string body", body: "hello", opts: func() []Option { return nil }, wantBody: "hello", wantHits: 3},
+		{
+			name: "json body",
+			opts: func() []Option {
+				return []Option{WithInJson(map[string]int{"a": 1})}
+			},
+			wantBody: `{"a":1}`,
+			wantHits: 3,
+		},
+		{
+			name: "seekable stream",
+			opts: func() []Option {
+				r := bytes.NewReader([]byte("skip-stream"))
+				_, _ = r.Seek(5, io.SeekStart) // 从当前位置重放
+				return []Option{WithInBodyStream(r)}
+			},
+			wantBody: "stream",
+			wantHits: 3,
+		},
+		{
+			name: "not seekable stream",
+			opts: func() []Option {
+				return []Option{WithInBodyStream(io.MultiReader(strings.NewReader("stream")))}
+			},
+			wantBody: "stream",
+			wantHits: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newRetryServer(t, 503, 503, 503)
+			c := newRetryTestClient(t)
+			req := NewRequest("POST", srv.URL, tt.body)
+			req.applyOptions(append(tt.opts(), WithRetry(policy))...)
+			if _, err := c.Do(WithoutZAppFilter(context.Background()), req); err != nil {
+				t.Fatal(err)
+			}
+			if got := srv.hits(); got != tt.wantHits {
+				t.Fatalf("hits = %d, want %d", got, tt.wantHits)
+			}
+			for i, body := range srv.bodies {
+				if body != tt.wantBody {
+					t.Errorf("attempt %d body = %q, want %q", i+1, body, tt.wantBody)
+				}
+			}
+		})
+	}
+}
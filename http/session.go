@@ -0,0 +1,168 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// 会话, 包装一个客户端并维护自己的cookie, 默认header和基础地址, 适用于需要登录的多步请求.
+// 请求依然经过客户端的 zapp filter 和中间件
+type Session struct {
+	Client
+	jar *sessionJar
+
+	mx      sync.RWMutex
+	baseUrl string
+	header  Header
+}
+
+// 创建会话, name 为客户端名
+func NewSession(name string) *Session {
+	return NewSessionWithClient(NewClient(name))
+}
+
+// 使用已有的客户端创建会话
+func NewSessionWithClient(c Client) *Session {
+	return &Session{
+		Client: c,
+		jar:    newSessionJar(),
+		header: make(Header),
+	}
+}
+
+// 设置基础地址, 请求的path不是完整url时会拼接在它后面, 优先级高于客户端配置的基础地址
+func (s *Session) SetBaseUrl(baseUrl string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.baseUrl = baseUrl
+}
+
+// 设置默认header, 请求中未设置的header会使用这里的值
+func (s *Session) SetHeader(key, value string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.header.Set(key, value)
+}
+
+// 删除默认header
+func (s *Session) DelHeader(key string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.header.Del(key)
+}
+
+// 获取会话的cookie jar
+func (s *Session) Jar() http.CookieJar {
+	return s.jar
+}
+
+// 获取发送到 u 时会携带的cookie
+func (s *Session) Cookies(u string) ([]*http.Cookie, error) {
+	uu, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	return s.jar.Cookies(uu), nil
+}
+
+// 设置 u 收到的cookie
+func (s *Session) SetCookies(u string, cookies []*http.Cookie) error {
+	uu, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	s.jar.SetCookies(uu, cookies)
+	return nil
+}
+
+// 清除所有cookie
+func (s *Session) ClearCookies() {
+	s.jar.clear()
+}
+
+// 将cookie保存到文件, format 支持 CookieFormatJSON 和 CookieFormatNetscape, 会话cookie也会保存
+func (s *Session) SaveCookies(path, format string) error {
+	return s.jar.save(path, format)
+}
+
+// 从文件加载cookie, 已过期的cookie会被忽略
+func (s *Session) LoadCookies(path, format string) error {
+	return s.jar.load(path, format)
+}
+
+// 会话的请求选项, 放在最后以便补全请求中未设置的header
+func (s *Session) withSession(path string, opts []Option) (string, []Option) {
+	s.mx.RLock()
+	path = joinBaseUrl(s.baseUrl, path)
+	header := s.header.Clone()
+	s.mx.RUnlock()
+
+	opts = append(opts[:len(opts):len(opts)], func(r *Request) {
+		s.apply(r, header)
+	})
+	return path, opts
+}
+
+func (s *Session) apply(r *Request, header Header) {
+	if r.Header == nil {
+		r.Header = make(Header, len(header))
+	}
+	for k, v := range header {
+		if _, ok := r.Header[k]; !ok {
+			r.Header[k] = v
+		}
+	}
+	r.jar = s.jar
+}
+
+func (s *Session) Get(ctx context.Context, path string, opts ...Option) (*Response, error) {
+	path, opts = s.withSession(path, opts)
+	return s.Client.Get(ctx, path, opts...)
+}
+
+func (s *Session) Head(ctx context.Context, path string, opts ...Option) (*Response, error) {
+	path, opts = s.withSession(path, opts)
+	return s.Client.Head(ctx, path, opts...)
+}
+
+func (s *Session) Post(ctx context.Context, path string, reqBody []byte, opts ...Option) (*Response, error) {
+	path, opts = s.withSession(path, opts)
+	return s.Client.Post(ctx, path, reqBody, opts...)
+}
+
+func (s *Session) Put(ctx context.Context, path string, reqBody []byte, opts ...Option) (*Response, error) {
+	path, opts = s.withSession(path, opts)
+	return s.Client.Put(ctx, path, reqBody, opts...)
+}
+
+func (s *Session) Patch(ctx context.Context, path string, reqBody []byte, opts ...Option) (*Response, error) {
+	path, opts = s.withSession(path, opts)
+	return s.Client.Patch(ctx, path, reqBody, opts...)
+}
+
+func (s *Session) Delete(ctx context.Context, path string, reqBody []byte, opts ...Option) (*Response, error) {
+	path, opts = s.withSession(path, opts)
+	return s.Client.Delete(ctx, path, reqBody, opts...)
+}
+
+func (s *Session) Do(ctx context.Context, req *Request) (*Response, error) {
+	s.mx.RLock()
+	req.Path = joinBaseUrl(s.baseUrl, req.Path)
+	header := s.header.Clone()
+	s.mx.RUnlock()
+
+	s.apply(req, header)
+	return s.Client.Do(ctx, req)
+}
+
+func (s *Session) Download(ctx context.Context, url, dstPath string, opts ...Option) (*Response, error) {
+	url, opts = s.withSession(url, opts)
+	return s.Client.Download(ctx, url, dstPath, opts...)
+}
+
+func (s *Session) Dial(ctx context.Context, url string, opts ...Option) (*WSConn, *Response, error) {
+	url, opts = s.withSession(url, opts)
+	return s.Client.Dial(ctx, url, opts...)
+}
@@ -0,0 +1,303 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// cookie文件格式
+const (
+	CookieFormatJSON     = "json"
+	CookieFormatNetscape = "netscape" // curl, wget 等使用的 cookies.txt 格式
+)
+
+// 保存到文件的cookie
+type savedCookie struct {
+	Domain   string    `json:"domain"`
+	HostOnly bool      `json:"hostOnly,omitempty"` // 只发送到 Domain 本身, 不包含子域名
+	Origin   string    `json:"origin,omitempty"`   // 设置cookie的host, 加载时以它作为来源, 为空时使用 Domain
+	Path     string    `json:"path"`
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Expires  time.Time `json:"expires,omitempty"` // 零值表示会话cookie
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"httpOnly,omitempty"`
+}
+
+func (c *savedCookie) key() string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+func (c *savedCookie) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+// 会话的cookie jar, cookiejar.Jar 无法遍历cookie, 这里额外记录收到的cookie用于保存到文件
+type sessionJar struct {
+	mx      sync.Mutex
+	jar     *cookiejar.Jar
+	cookies map[string]*savedCookie
+}
+
+func newSessionJar() *sessionJar {
+	jar, _ := cookiejar.New(nil) // 不设置 PublicSuffixList 时不会返回错误
+	return &sessionJar{jar: jar, cookies: make(map[string]*savedCookie)}
+}
+
+func (j *sessionJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+	return j.jar.Cookies(u)
+}
+
+func (j *sessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+	j.jar.SetCookies(u, cookies)
+
+	now := time.Now()
+	for _, c := range cookies {
+		sc := &savedCookie{
+			Domain:   strings.ToLower(strings.TrimPrefix(c.Domain, ".")),
+			Path:     c.Path,
+			Name:     c.Name,
+			Value:    c.Value,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}
+		sc.Origin = strings.ToLower(u.Hostname())
+		if sc.Domain == "" {
+			sc.Domain = sc.Origin
+			sc.HostOnly = true
+		}
+		if sc.Path == "" || sc.Path[0] != '/' {
+			sc.Path = defaultCookiePath(u.Path)
+		}
+		switch {
+		case c.MaxAge < 0:
+			sc.Expires = now
+		case c.MaxAge > 0:
+			sc.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+
+		if sc.expired(now) {
+			delete(j.cookies, sc.key())
+			continue
+		}
+		// 只记录jar接受的cookie, 如 Domain 和来源host不匹配的cookie会被jar拒绝
+		if !j.stored(u, sc) {
+			continue
+		}
+		j.cookies[sc.key()] = sc
+	}
+}
+
+// 判断cookie是否被jar保存, 接受的cookie一定会发送回来源host
+func (j *sessionJar) stored(u *url.URL, sc *savedCookie) bool {
+	for _, c := range j.jar.Cookies(&url.URL{Scheme: "https", Host: u.Host, Path: sc.Path}) {
+		if c.Name == sc.Name && c.Value == sc.Value {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *sessionJar) clear() {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+	j.jar, _ = cookiejar.New(nil)
+	j.cookies = make(map[string]*savedCookie)
+}
+
+// 返回未过期的cookie, 按 domain, path, name 排序
+func (j *sessionJar) snapshot() []*savedCookie {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+
+	now := time.Now()
+	ret := make([]*savedCookie, 0, len(j.cookies))
+	for k, c := range j.cookies {
+		if c.expired(now) {
+			delete(j.cookies, k)
+			continue
+		}
+		cp := *c
+		ret = append(ret, &cp)
+	}
+	sort.Slice(ret, func(i, k int) bool { return ret[i].key() < ret[k].key() })
+	return ret
+}
+
+func (j *sessionJar) save(path, format string) error {
+	cookies := j.snapshot()
+
+	var data []byte
+	switch format {
+	case CookieFormatJSON:
+		bs, err := sonic.ConfigStd.MarshalIndent(cookies, "", "  ")
+		if err != nil {
+			return err
+		}
+		data = bs
+	case CookieFormatNetscape:
+		data = marshalNetscapeCookies(cookies)
+	default:
+		return fmt.Errorf("不支持的cookie文件格式: %s", format)
+	}
+
+	// 先写临时文件再替换, 避免写入中断时损坏原文件
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0o600); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (j *sessionJar) load(path, format string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var cookies []*savedCookie
+	switch format {
+	case CookieFormatJSON:
+		if err = sonic.Unmarshal(data, &cookies); err != nil {
+			return fmt.Errorf("解析cookie文件失败: %v", err)
+		}
+	case CookieFormatNetscape:
+		if cookies, err = unmarshalNetscapeCookies(data); err != nil {
+			return fmt.Errorf("解析cookie文件失败: %v", err)
+		}
+	default:
+		return fmt.Errorf("不支持的cookie文件格式: %s", format)
+	}
+
+	now := time.Now()
+	for _, c := range cookies {
+		if c.Domain == "" || c.Name == "" || c.expired(now) {
+			continue
+		}
+		scheme := "http"
+		if c.Secure {
+			scheme = "https"
+		}
+		origin := c.Origin
+		if origin == "" {
+			origin = c.Domain
+		}
+		u := &url.URL{Scheme: scheme, Host: origin, Path: c.Path}
+		hc := &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}
+		if !c.HostOnly {
+			hc.Domain = c.Domain
+		}
+		j.SetCookies(u, []*http.Cookie{hc})
+	}
+	return nil
+}
+
+// 按 rfc6265 计算默认的cookie路径
+func defaultCookiePath(path string) string {
+	if path == "" || path[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+const netscapeHttpOnlyPrefix = "#HttpOnly_"
+
+// 每行为 domain, 是否包含子域名, path, secure, 过期时间(unix秒, 0表示会话cookie), name, value, 以tab分隔
+func marshalNetscapeCookies(cookies []*savedCookie) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Netscape HTTP Cookie File\n\n")
+	for _, c := range cookies {
+		domain, includeSub := c.Domain, "FALSE"
+		if !c.HostOnly {
+			domain, includeSub = "."+c.Domain, "TRUE"
+		}
+		if c.HttpOnly {
+			domain = netscapeHttpOnlyPrefix + domain
+		}
+		var expires int64
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Unix()
+		}
+		fmt.Fprintf(&buf, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, includeSub, c.Path, strings.ToUpper(strconv.FormatBool(c.Secure)), expires, c.Name, c.Value)
+	}
+	return buf.Bytes()
+}
+
+func unmarshalNetscapeCookies(data []byte) ([]*savedCookie, error) {
+	var cookies []*savedCookie
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimRight(sc.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, netscapeHttpOnlyPrefix)
+		line = strings.TrimPrefix(line, netscapeHttpOnlyPrefix)
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("第%d行格式错误", n)
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("第%d行过期时间错误: %v", n, err)
+		}
+		c := &savedCookie{
+			Domain:   strings.ToLower(strings.TrimPrefix(fields[0], ".")),
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		if expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies, sc.Err()
+}
@@ -0,0 +1,293 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 请求签名器, 在请求发送前调用, 此时body已经序列化, header和请求参数都已确定.
+//
+// body 为最终发送的body数据, 流式body(WithInBodyStream, WithInMultipart)时 body 为nil且 req.Body 不为nil,
+// 签名器可以选择不对body签名或返回错误. 每次重试都会重新签名
+type Signer interface {
+	Sign(req *http.Request, body []byte) error
+}
+
+// 签名函数
+type SignerFunc func(req *http.Request, body []byte) error
+
+func (f SignerFunc) Sign(req *http.Request, body []byte) error { return f(req, body) }
+
+// 设置请求签名器
+func WithSigner(s Signer) Option {
+	return func(r *Request) {
+		r.signer = s
+	}
+}
+
+// 返回为请求设置签名器的中间件, 用于对客户端的所有请求签名, 请求通过 WithSigner 设置了签名器时不会覆盖
+//
+//	c.Use(http.SignMiddleware(&http.SigV4Signer{...}))
+func SignMiddleware(s Signer) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			if r.signer == nil {
+				r.signer = s
+			}
+			return next(ctx, r)
+		}
+	}
+}
+
+// 流式body不支持对body签名时返回这个错误
+var ErrSignStreamBody = errors.New("http: signer does not support stream body")
+
+func isStreamBody(req *http.Request, body []byte) bool {
+	return body == nil && req.Body != nil && req.Body != http.NoBody
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// 按 rfc3986 编码, 只保留非保留字符
+func uriEscape(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			sb.WriteByte(c)
+			continue
+		}
+		sb.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+	}
+	return sb.String()
+}
+
+// 规范化的请求参数, 按key和value排序
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEscape(k)+"="+uriEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func requestHost(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+// AWS Signature Version 4 签名器, 适用于 S3 兼容存储和其它 AWS 风格的api
+type SigV4Signer struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string // 临时凭证的 token, 会设置到 X-Amz-Security-Token
+	Region       string // 如 us-east-1
+	Service      string // 如 s3
+
+	// 不对body签名, 使用 UNSIGNED-PAYLOAD. 流式body总是不对body签名
+	UnsignedPayload bool
+	// 获取当前时间, 为nil时使用 time.Now
+	Now func() time.Time
+}
+
+func (s *SigV4Signer) Sign(req *http.Request, body []byte) error {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	payloadHash := "UNSIGNED-PAYLOAD"
+	if !s.UnsignedPayload && !isStreamBody(req, body) {
+		payloadHash = sha256Hex(body)
+	}
+
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", amzDate)
+	// 和 AWS SDK 一致, 只有 s3 和不对body签名时才需要这个header
+	if s.Service == "s3" || payloadHash == "UNSIGNED-PAYLOAD" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	signedHeaders, canonicalHeaders := s.canonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s.canonicalURI(req.URL),
+		canonicalQuery(req.URL),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/" + s.Service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+	return nil
+}
+
+// 规范化的路径, s3 只编码一次, 其它服务编码两次
+func (s *SigV4Signer) canonicalURI(u *url.URL) string {
+	path := u.Path
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		seg = uriEscape(seg)
+		if s.Service != "s3" {
+			seg = uriEscape(seg)
+		}
+		segments[i] = seg
+	}
+	return strings.Join(segments, "/")
+}
+
+// 对 host, content-type, content-md5 和所有 x-amz-* 头签名
+func (s *SigV4Signer) canonicalHeaders(req *http.Request) (signed, canonical string) {
+	headers := map[string]string{"host": requestHost(req)}
+	for k, v := range req.Header {
+		name := strings.ToLower(k)
+		if name != "content-type" && name != "content-md5" && !strings.HasPrefix(name, "x-amz-") {
+			continue
+		}
+		values := make([]string, len(v))
+		for i, s := range v {
+			values[i] = strings.Join(strings.Fields(s), " ")
+		}
+		headers[name] = strings.Join(values, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return strings.Join(names, ";"), sb.String()
+}
+
+// 通用 HMAC-SHA256 签名器.
+//
+// 默认的待签名字符串为以下各项以 \n 连接:
+//
+//	请求方法
+//	编码后的路径
+//	规范化的请求参数(按key和value排序)
+//	时间戳(unix秒)
+//	SignedHeaders 中每个header的 小写名:值
+//	body的sha256(hex)
+//
+// 签名结果写入 SignatureHeader, 时间戳写入 TimestampHeader, KeyId 不为空时写入 KeyIdHeader
+type HMACSigner struct {
+	KeyId  string
+	Secret string
+
+	SignatureHeader string   // 签名写入的header, 默认 X-Signature
+	TimestampHeader string   // 时间戳写入的header, 默认 X-Timestamp
+	KeyIdHeader     string   // KeyId 写入的header, 默认 X-Key-Id
+	SignedHeaders   []string // 参与签名的header
+	Base64          bool     // 签名使用base64编码, 默认使用hex编码
+
+	// 自定义待签名字符串, 不为nil时替代默认的待签名字符串
+	StringToSign func(req *http.Request, body []byte, timestamp string) string
+	// 获取当前时间, 为nil时使用 time.Now
+	Now func() time.Time
+}
+
+func (s *HMACSigner) Sign(req *http.Request, body []byte) error {
+	if isStreamBody(req, body) {
+		return ErrSignStreamBody
+	}
+
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	timestamp := strconv.FormatInt(now().Unix(), 10)
+
+	req.Header.Set(defaultStr(s.TimestampHeader, "X-Timestamp"), timestamp)
+	if s.KeyId != "" {
+		req.Header.Set(defaultStr(s.KeyIdHeader, "X-Key-Id"), s.KeyId)
+	}
+
+	var stringToSign string
+	if s.StringToSign != nil {
+		stringToSign = s.StringToSign(req, body, timestamp)
+	} else {
+		lines := []string{req.Method, req.URL.EscapedPath(), canonicalQuery(req.URL), timestamp}
+		for _, name := range s.SignedHeaders {
+			if strings.EqualFold(name, "host") {
+				lines = append(lines, "host:"+requestHost(req))
+				continue
+			}
+			lines = append(lines, strings.ToLower(name)+":"+strings.TrimSpace(req.Header.Get(name)))
+		}
+		lines = append(lines, sha256Hex(body))
+		stringToSign = strings.Join(lines, "\n")
+	}
+
+	sum := hmacSHA256([]byte(s.Secret), stringToSign)
+	signature := hex.EncodeToString(sum)
+	if s.Base64 {
+		signature = base64.StdEncoding.EncodeToString(sum)
+	}
+
+	req.Header.Set(defaultStr(s.SignatureHeader, "X-Signature"), signature)
+	return nil
+}
+
+func defaultStr(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// StatusError.Body 最多保留的字节数
+const statusErrorBodyLimit = 1024
+
+// 流式响应状态码不符合预期时最多读取的字节数, 超过时 StatusError.Truncated 为true
+const statusErrorStreamLimit = 1 << 20
+
+// 响应状态码不符合预期时返回的错误
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Header     Header
+	Body       string // 响应body的片段, 最多保留 1024 字节
+	Truncated  bool   // Body 是否被截断
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("http: unexpected status %s", e.Status)
+	}
+	suffix := ""
+	if e.Truncated {
+		suffix = "..."
+	}
+	return fmt.Sprintf("http: unexpected status %s: %s%s", e.Status, e.Body, suffix)
+}
+
+// 响应状态码检查策略
+type statusPolicy struct {
+	enable bool
+	codes  []int // 期望的状态码, 为空表示 2xx
+}
+
+func (p *statusPolicy) expected(code int) bool {
+	if len(p.codes) == 0 {
+		return code >= 200 && code < 300
+	}
+	return slices.Contains(p.codes, code)
+}
+
+// 检查响应状态码, 不符合预期时返回 *StatusError, 如果设置了 WithOutErrorJson 会解析错误数据
+func (c *cli) checkStatus(conn *clientConn, r *Request, sp *Response) error {
+	policy := conn.status
+	if r.status != nil {
+		policy = *r.status
+	}
+	if !policy.enable && r.outErrorPtr == nil {
+		return nil
+	}
+	if policy.expected(sp.StatusCode) {
+		return nil
+	}
+
+	body, truncated := sp.Body, false
+	if r.OutIsStream {
+		bs, err := io.ReadAll(io.LimitReader(sp.BodyStream, statusErrorStreamLimit+1))
+		_ = sp.BodyStream.Close()
+		if errors.Is(err, ErrResponseTooLarge) { // 超过 MaxResponseBytes
+			truncated = true
+		} else if err != nil {
+			return err
+		}
+		if len(bs) > statusErrorStreamLimit {
+			bs, truncated = bs[:statusErrorStreamLimit], true
+		}
+		body = string(bs)
+	}
+
+	e := &StatusError{
+		StatusCode: sp.StatusCode,
+		Status:     sp.Status,
+		Header:     sp.Header,
+		Body:       body,
+		Truncated:  truncated,
+	}
+	if len(e.Body) > statusErrorBodyLimit {
+		e.Body, e.Truncated = e.Body[:statusErrorBodyLimit], true
+	}
+
+	if r.outErrorPtr != nil && body != "" {
+		codec, err := getCodec(CodecJson)
+		if err != nil {
+			return err
+		}
+		if err = codec.Unmarshal([]byte(body), r.outErrorPtr); err != nil {
+			return fmt.Errorf("%w; 解析错误数据失败: %v", e, err)
+		}
+	}
+	return e
+}
@@ -0,0 +1,47 @@
+package http
+
+import (
+	"context"
+	"errors"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 流式响应的错误body只读取有限的字节数
+func TestCheckStatusStreamLimit(t *testing.T) {
+	chunk := []byte(strings.Repeat("a", 32<<10))
+	srv := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		w.WriteHeader(stdhttp.StatusInternalServerError)
+		// 不断写入直到客户端关闭连接
+		for {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		maxBytes int64
+	}{
+		{name: "unlimited response"},
+		{name: "max response bytes", maxBytes: 4096},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newRetryTestClient(t)
+			_, err := c.Get(WithoutZAppFilter(context.Background()), srv.URL,
+				WithOutIsStream(true), WithExpectStatus(stdhttp.StatusOK), WithMaxResponseBytes(tt.maxBytes))
+			var e *StatusError
+			if !errors.As(err, &e) {
+				t.Fatalf("err = %v, want StatusError", err)
+			}
+			if !e.Truncated || len(e.Body) != statusErrorBodyLimit {
+				t.Errorf("Truncated = %v, len(Body) = %d, want true, %d", e.Truncated, len(e.Body), statusErrorBodyLimit)
+			}
+		})
+	}
+}
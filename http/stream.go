@@ -0,0 +1,128 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+// 流处理函数返回这个错误时停止读取, 且请求不会返回错误
+var ErrStopStream = errors.New("http: stop stream")
+
+// Server-Sent Events 事件
+type SSEEvent struct {
+	ID    string // 事件id
+	Event string // 事件类型, 为空表示 message
+	Data  string // 事件数据, 多行 data 以 \n 连接
+	Retry int    // 服务器建议的重连时间(毫秒), 0表示未设置
+}
+
+// 读取响应body的流处理函数
+type streamHandler func(ctx context.Context, body io.Reader) error
+
+// 按 text/event-stream 格式逐个解析事件, 流结束时没有以空行结尾的最后一个事件不完整, 会被丢弃
+func readSSE(ctx context.Context, body io.Reader, handler func(ctx context.Context, event *SSEEvent) error) error {
+	br := bufio.NewReader(body)
+	var event SSEEvent
+	var data strings.Builder
+	var hasData bool
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		line, err := br.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		// 空行表示一个事件结束
+		if line == "" {
+			if hasData {
+				event.Data = data.String()
+				if err := handler(ctx, &event); err != nil {
+					return err
+				}
+			}
+			event = SSEEvent{ID: event.ID}
+			data.Reset()
+			hasData = false
+			continue
+		}
+		if strings.HasPrefix(line, ":") { // 注释
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				event.ID = value
+			}
+		case "retry":
+			// 只接受由数字组成的值
+			if n, err := strconv.Atoi(value); err == nil && strings.Trim(value, "0123456789") == "" {
+				event.Retry = n
+			}
+		}
+	}
+}
+
+// 按 application/x-ndjson 格式逐行解析json
+func readNDJSON(ctx context.Context, body io.Reader, newPtr func() interface{}, handler func(ctx context.Context, v interface{}) error) error {
+	br := bufio.NewReader(body)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		line, err := br.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		v := newPtr()
+		if err := sonic.Unmarshal(line, v); err != nil {
+			return err
+		}
+		if err := handler(ctx, v); err != nil {
+			return err
+		}
+	}
+}
+
+// 处理流式响应, 读取完毕后关闭body
+func handleStream(ctx context.Context, handler streamHandler, body io.ReadCloser) error {
+	defer body.Close()
+	err := handler(ctx, body)
+	if errors.Is(err, ErrStopStream) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadSSE(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []SSEEvent
+	}{
+		{
+			name:  "single event",
+			input: "data: hello\n\n",
+			want:  []SSEEvent{{Data: "hello"}},
+		},
+		{
+			name:  "multi-line data",
+			input: "data: line1\ndata: line2\ndata:line3\n\n",
+			want:  []SSEEvent{{Data: "line1\nline2\nline3"}},
+		},
+		{
+			name:  "event id and retry",
+			input: "event: update\nid: 42\nretry: 3000\ndata: {\"a\":1}\n\n",
+			want:  []SSEEvent{{ID: "42", Event: "update", Retry: 3000, Data: `{"a":1}`}},
+		},
+		{
+			name:  "id is kept for later events",
+			input: "id: 1\ndata: a\n\ndata: b\n\nid: 2\ndata: c\n\n",
+			want:  []SSEEvent{{ID: "1", Data: "a"}, {ID: "1", Data: "b"}, {ID: "2", Data: "c"}},
+		},
+		{
+			name:  "invalid retry and id are ignored",
+			input: "retry: 1s\nid: a\x00b\ndata: x\n\nretry: -5\ndata: y\n\n",
+			want:  []SSEEvent{{Data: "x"}, {Data: "y"}},
+		},
+		{
+			name:  "comments",
+			input: ": keep-alive\ndata: a\n: inside\ndata: b\n\n:\n\n",
+			want:  []SSEEvent{{Data: "a\nb"}},
+		},
+		{
+			name:  "event without data is not dispatched",
+			input: "event: ping\n\ndata: x\n\n",
+			want:  []SSEEvent{{Data: "x"}},
+		},
+		{
+			name:  "crlf line endings",
+			input: "event: e\r\ndata: a\r\ndata: b\r\n\r\n",
+			want:  []SSEEvent{{Event: "e", Data: "a\nb"}},
+		},
+		{
+			name:  "empty data line",
+			input: "data\ndata: x\n\n",
+			want:  []SSEEvent{{Data: "\nx"}},
+		},
+		{
+			name:  "incomplete final event is discarded",
+			input: "data: a\n\ndata: b\n",
+			want:  []SSEEvent{{Data: "a"}},
+		},
+		{
+			name:  "truncated final event is discarded",
+			input: "data: a\n\nid: 7\ndata: b",
+			want:  []SSEEvent{{Data: "a"}},
+		},
+		{
+			name:  "empty stream",
+			input: "",
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []SSEEvent
+			err := readSSE(context.Background(), strings.NewReader(tt.input), func(ctx context.Context, event *SSEEvent) error {
+				got = append(got, *event)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadNDJSON(t *testing.T) {
+	type item struct {
+		N int `json:"n"`
+	}
+	tests := []struct {
+		name    string
+		input   string
+		want    []int
+		wantErr bool
+	}{
+		{name: "lines", input: "{\"n\":1}\n{\"n\":2}\n", want: []int{1, 2}},
+		{name: "blank lines and crlf", input: "{\"n\":1}\r\n\r\n  \n{\"n\":2}\r\n", want: []int{1, 2}},
+		{name: "final line without newline", input: "{\"n\":1}\n{\"n\":2}", want: []int{1, 2}},
+		{name: "invalid json", input: "{\"n\":1}\n{n}\n", want: []int{1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			err := readNDJSON(context.Background(), strings.NewReader(tt.input), func() interface{} { return new(item) },
+				func(ctx context.Context, v interface{}) error {
+					got = append(got, v.(*item).N)
+					return nil
+				})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("items = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSSEStop(t *testing.T) {
+	body := &closeRecorder{Reader: strings.NewReader("data: a\n\ndata: b\n\ndata: c\n\n")}
+	var got []string
+	err := handleStream(context.Background(), func(ctx context.Context, r io.Reader) error {
+		return readSSE(ctx, r, func(ctx context.Context, event *SSEEvent) error {
+			got = append(got, event.Data)
+			if event.Data == "b" {
+				return ErrStopStream
+			}
+			return nil
+		})
+	}, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("events = %v, want [a b]", got)
+	}
+	if !body.closed {
+		t.Error("body is not closed")
+	}
+
+	handlerErr := errors.New("handler failed")
+	err = readSSE(context.Background(), strings.NewReader("data: a\n\n"), func(ctx context.Context, event *SSEEvent) error {
+		return handlerErr
+	})
+	if !errors.Is(err, handlerErr) {
+		t.Errorf("err = %v, want handler error", err)
+	}
+}
+
+func TestOutSSE(t *testing.T) {
+	srv := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("id: 1\ndata: a\n\n"))
+		w.(stdhttp.Flusher).Flush()
+		_, _ = w.Write([]byte("data: b"))
+	}))
+	defer srv.Close()
+
+	conf := newConfig()
+	if err := conf.Check(); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClientWithRoundTripper("test-stream", conf, stdhttp.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []SSEEvent
+	_, err = c.Get(WithoutZAppFilter(context.Background()), srv.URL, WithOutSSE(func(ctx context.Context, event *SSEEvent) error {
+		got = append(got, *event)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SSEEvent{{ID: "1", Data: "a"}} // 连接断开时不完整的事件被丢弃
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %+v, want %+v", got, want)
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
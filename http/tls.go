@@ -0,0 +1,113 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// tls配置, 所有字段都是可比较的, 相同的配置会复用同一个 http.Transport
+type TLSConfig struct {
+	InsecureSkipVerify bool   // 跳过x509校验
+	CertFile           string // 客户端证书文件
+	KeyFile            string // 客户端证书私钥文件
+	CertPEM            string // 客户端证书内容, 优先级高于 CertFile
+	KeyPEM             string // 客户端证书私钥内容, 优先级高于 KeyFile
+	CAFile             string // 自定义ca证书文件
+	CAPEM              string // 自定义ca证书内容, 和 CAFile 同时设置时都会加入
+	ServerName         string // 覆盖用于校验证书的服务器名
+	MinVersion         uint16 // 最小tls版本, 如 tls.VersionTLS12
+}
+
+// 用o中非零值的字段覆盖t, 返回新的配置
+func (t TLSConfig) merge(o TLSConfig) TLSConfig {
+	if o.InsecureSkipVerify {
+		t.InsecureSkipVerify = true
+	}
+	if o.CertFile != "" || o.KeyFile != "" || o.CertPEM != "" || o.KeyPEM != "" {
+		t.CertFile, t.KeyFile, t.CertPEM, t.KeyPEM = o.CertFile, o.KeyFile, o.CertPEM, o.KeyPEM
+	}
+	if o.CAFile != "" || o.CAPEM != "" {
+		t.CAFile, t.CAPEM = o.CAFile, o.CAPEM
+	}
+	if o.ServerName != "" {
+		t.ServerName = o.ServerName
+	}
+	if o.MinVersion != 0 {
+		t.MinVersion = o.MinVersion
+	}
+	return t
+}
+
+// 构建 tls.Config, 零值配置返回nil表示使用标准库的默认配置
+func (t TLSConfig) build() (*tls.Config, error) {
+	if t == (TLSConfig{}) {
+		return nil, nil
+	}
+
+	conf := &tls.Config{
+		InsecureSkipVerify: t.InsecureSkipVerify,
+		ServerName:         t.ServerName,
+		MinVersion:         t.MinVersion,
+	}
+
+	certPEM, keyPEM := []byte(t.CertPEM), []byte(t.KeyPEM)
+	if len(certPEM) == 0 && t.CertFile != "" {
+		bs, err := os.ReadFile(t.CertFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取客户端证书失败: %v", err)
+		}
+		certPEM = bs
+	}
+	if len(keyPEM) == 0 && t.KeyFile != "" {
+		bs, err := os.ReadFile(t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取客户端证书私钥失败: %v", err)
+		}
+		keyPEM = bs
+	}
+	if len(certPEM) > 0 || len(keyPEM) > 0 {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("解析客户端证书失败: %v", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	if t.CAFile != "" || t.CAPEM != "" {
+		pool := x509.NewCertPool()
+		if t.CAFile != "" {
+			bs, err := os.ReadFile(t.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("读取ca证书失败: %v", err)
+			}
+			if !pool.AppendCertsFromPEM(bs) {
+				return nil, errors.New("ca证书文件中没有有效的证书")
+			}
+		}
+		if t.CAPEM != "" && !pool.AppendCertsFromPEM([]byte(t.CAPEM)) {
+			return nil, errors.New("ca证书内容中没有有效的证书")
+		}
+		conf.RootCAs = pool
+	}
+	return conf, nil
+}
+
+// 解析tls版本, 支持 1.0, 1.1, 1.2, 1.3
+func parseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("不支持的tls版本: %s", version)
+}
@@ -0,0 +1,459 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zly-app/zapp/filter"
+	"github.com/zly-app/zapp/pkg/utils"
+)
+
+// 默认提供给filter的body最大捕获字节数
+const defaultMaxCaptureBytes = 64 << 10
+
+var rawStdDialer = &net.Dialer{
+	Timeout:   30 * time.Second,
+	KeepAlive: 30 * time.Second,
+}
+var rawStdTransport = newRawStdTransport(nil)
+
+func newRawStdTransport(tlsConf *tls.Config) *http.Transport {
+	return &http.Transport{
+		Proxy:                 proxyResolve,
+		DialContext:           stdDNS.dialContext(rawStdDialer),
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       tlsConf,
+	}
+}
+
+// 按tls配置缓存的 http.Transport, 相同配置的 Transport 复用连接池
+var rawStdTransports sync.Map // TLSConfig -> *http.Transport
+
+var NewTransport = func(name string, insecureSkipVerify bool) http.RoundTripper {
+	return Transport{Name: name, InsecureSkipVerify: insecureSkipVerify}
+}
+
+// 使用请求选项创建 Transport, 支持 WithTimeout, WithProxy, WithInsecureSkipVerify 和tls相关的选项, 其它选项会被忽略
+func NewTransportWithOptions(name string, opts ...Option) http.RoundTripper {
+	r := &Request{}
+	r.applyOptions(opts...)
+	return Transport{
+		Name:               name,
+		InsecureSkipVerify: r.InsecureSkipVerify,
+		Timeout:            r.Timeout,
+		Proxy:              r.Proxy,
+		TLS:                r.tls,
+	}
+}
+
+// 经过 zapp filter 的 http.RoundTripper.
+//
+// 提供给filter的请求和响应body最多捕获 MaxCaptureBytes 字节, 不会影响实际发送和接收的数据.
+// 无法确定长度的请求body在发送时边读边捕获, 响应body在返回前最多读取捕获大小的数据, 读取的部分会放回body前面.
+// 流式响应(text/event-stream, application/x-ndjson)不会被读取
+// filter看到的header和body会按 Redact 脱敏, filter对header, 方法和地址的修改会同步到实际请求, 对响应的修改会同步到实际响应, 包含脱敏值的部分除外
+type Transport struct {
+	Name               string
+	InsecureSkipVerify bool
+	Timeout            time.Duration // 超时, 包含读取响应body的时间, 0表示不限制
+	Proxy              string        // 代理地址, 为空时使用环境变量中的代理
+	TLS                TLSConfig     // tls配置
+	MaxCaptureBytes    int64         // 提供给filter的body最大捕获字节数, 0表示使用默认值64KB, 小于0表示不捕获
+	Redact             *RedactPolicy // 提供给filter的header和body的脱敏策略, 为nil时只对默认的header脱敏
+}
+
+type roundTripReq struct {
+	Method        string
+	Path          string
+	Body          string
+	BodyTruncated bool `json:"BodyTruncated,omitempty"` // Body 只包含了部分数据
+
+	Header Header // 请求head
+	Params Values // 请求参数
+	req    *http.Request
+}
+type roundTripResponse struct {
+	Body          string
+	BodyStream    io.ReadCloser // 注意, 读取完毕需要使用者自行调用 Close
+	BodyTruncated bool          `json:"BodyTruncated,omitempty"` // 响应body没有被捕获或只捕获了部分数据
+
+	Status        string
+	StatusCode    int
+	ContentLength int64
+	Header        Header
+	Uncompressed  bool
+	rsp           *http.Response
+}
+
+func (t Transport) redact() *RedactPolicy {
+	if t.Redact == nil {
+		return defaultRedactPolicy
+	}
+	return t.Redact
+}
+
+// 对捕获的请求body脱敏
+func (r *roundTripReq) redactBody(p *RedactPolicy) {
+	var truncated bool
+	r.Body, truncated = p.Body(r.Body)
+	r.BodyTruncated = r.BodyTruncated || truncated
+}
+
+func (t Transport) maxCaptureBytes() int64 {
+	if t.MaxCaptureBytes == 0 {
+		return defaultMaxCaptureBytes
+	}
+	return max(t.MaxCaptureBytes, 0)
+}
+
+// 获取实际发送请求的 http.Transport
+func (t Transport) transport() (*http.Transport, error) {
+	tlsConf := t.TLS
+	if t.InsecureSkipVerify {
+		tlsConf.InsecureSkipVerify = true
+	}
+	if tlsConf == (TLSConfig{}) {
+		return rawStdTransport, nil
+	}
+	if v, ok := rawStdTransports.Load(tlsConf); ok {
+		return v.(*http.Transport), nil
+	}
+
+	conf, err := tlsConf.build()
+	if err != nil {
+		return nil, err
+	}
+	v, _ := rawStdTransports.LoadOrStore(tlsConf, newRawStdTransport(conf))
+	return v.(*http.Transport), nil
+}
+
+// 应用超时和代理后发送请求, 超时在响应body关闭后才会取消
+func (t Transport) roundTrip(req *http.Request) (*http.Response, error) {
+	rt, err := t.transport()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := req.Context()
+	if t.Proxy != "" {
+		ctx = saveProxy2Ctx(ctx, t.Proxy)
+	}
+	cancel := context.CancelFunc(func() {})
+	if t.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+	}
+	if ctx != req.Context() {
+		req = req.WithContext(ctx)
+	}
+
+	rsp, err := rt.RoundTrip(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	rsp.Body = &cancelReadCloser{ReadCloser: rsp.Body, cancel: cancel}
+	return rsp, nil
+}
+
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isWithoutZAppFilter(req.Context()) {
+		return t.roundTrip(req)
+	}
+
+	ctx, chain := filter.GetClientFilter(req.Context(), DefaultComponentType, t.Name, req.Method)
+	meta := filter.GetCallMeta(ctx)
+	meta.AddCallersSkip(1)
+
+	// 附加trace
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	utils.Trace.SaveToHeaders(ctx, req.Header)
+
+	// filter看到的header和body是脱敏后的副本
+	redact := t.redact()
+	r := &roundTripReq{
+		Method: req.Method,
+		Path:   req.URL.String(),
+		Header: redact.Header(req.Header),
+		Params: req.URL.Query(),
+		req:    req,
+	}
+	capture, err := t.captureRequestBody(r)
+	if err != nil {
+		return nil, err
+	}
+	r.redactBody(redact)
+
+	base := &roundTripReq{Method: r.Method, Path: r.Path, Header: r.Header.Clone(), Params: cloneValues(r.Params)}
+	var httpRsp *http.Response
+	var spBase *roundTripResponse
+	var rawBody string
+	rsp, err := chain.Handle(ctx, r, func(ctx context.Context, req interface{}) (rsp interface{}, err error) {
+		// 附加主调信息
+		meta := filter.GetCallMeta(ctx)
+		filter.SaveCallerMeta2Header(r.req.Header, filter.CallerMeta{
+			CallerInstance: meta.CallerInstance(),
+			CallerEnv:      meta.CallerEnv(),
+			CallerService:  meta.CallerService(),
+			CallerMethod:   meta.CallerMethod(),
+		})
+
+		realReq, err := mergeRoundTripReq(r.req, base, req.(*roundTripReq))
+		if err != nil {
+			return nil, err
+		}
+		httpRsp, err = t.roundTrip(realReq)
+		if capture != nil {
+			r.Body, r.BodyTruncated = capture.captured()
+			r.redactBody(redact)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		sp := &roundTripResponse{}
+		sp.Status = httpRsp.Status
+		sp.StatusCode = httpRsp.StatusCode
+		sp.ContentLength = httpRsp.ContentLength
+		sp.Header = redact.Header(httpRsp.Header)
+		sp.Uncompressed = httpRsp.Uncompressed
+		sp.rsp = httpRsp
+		if err = t.captureResponseBody(sp); err != nil {
+			return nil, err
+		}
+		rawBody = sp.Body
+		var truncated bool
+		sp.Body, truncated = redact.Body(sp.Body)
+		sp.BodyTruncated = sp.BodyTruncated || truncated
+		spBase = &roundTripResponse{Body: sp.Body, BodyTruncated: sp.BodyTruncated, Status: sp.Status, StatusCode: sp.StatusCode, Header: sp.Header.Clone()}
+		return sp, nil
+	})
+	if err != nil {
+		if httpRsp != nil {
+			_ = httpRsp.Body.Close()
+		}
+		return nil, err
+	}
+	v, _ := rsp.(*roundTripResponse)
+	if httpRsp == nil {
+		// filter没有发送请求, 直接返回了响应
+		if v == nil {
+			return nil, errors.New("http: filter returned no response")
+		}
+		return v.toHttp(req), nil
+	}
+	if v != nil {
+		mergeRoundTripResponse(httpRsp, spBase, v, rawBody)
+	}
+	return httpRsp, nil
+}
+
+// 将filter对请求副本的修改同步到实际请求, 修改了方法或地址时返回新的请求. 包含脱敏值的header不会同步, body的修改不会同步
+func mergeRoundTripReq(req *http.Request, base, view *roundTripReq) (*http.Request, error) {
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	syncHeader(req.Header, base.Header, view.Header)
+
+	pathChanged, paramsChanged := view.Path != base.Path, view.Params.Encode() != base.Params.Encode()
+	if view.Method == base.Method && !pathChanged && !paramsChanged {
+		return req, nil
+	}
+	nr := *req
+	if view.Method != base.Method {
+		nr.Method = view.Method
+	}
+	if pathChanged || paramsChanged {
+		u := req.URL
+		if pathChanged {
+			var err error
+			if u, err = url.Parse(view.Path); err != nil {
+				return nil, err
+			}
+			nr.Host = u.Host
+		}
+		u2 := *u
+		if paramsChanged {
+			u2.RawQuery = view.Params.Encode()
+		}
+		nr.URL = &u2
+	}
+	return &nr, nil
+}
+
+// 将filter对响应副本的修改同步到实际响应, 包含脱敏值的header和被脱敏或截断的body不会同步
+func mergeRoundTripResponse(rsp *http.Response, base, view *roundTripResponse, rawBody string) {
+	if view.StatusCode != base.StatusCode {
+		rsp.StatusCode = view.StatusCode
+	}
+	if view.Status != base.Status {
+		rsp.Status = view.Status
+	}
+	syncHeader(rsp.Header, base.Header, view.Header)
+	if view.Body != base.Body && !base.BodyTruncated && base.Body == rawBody {
+		_ = rsp.Body.Close()
+		rsp.Body = io.NopCloser(strings.NewReader(view.Body))
+		rsp.ContentLength = int64(len(view.Body))
+		rsp.Header.Del("Content-Length")
+	}
+}
+
+// 将filter生成的响应转为 http.Response
+func (sp *roundTripResponse) toHttp(req *http.Request) *http.Response {
+	header := http.Header(sp.Header)
+	if header == nil {
+		header = make(http.Header)
+	}
+	body := io.ReadCloser(http.NoBody)
+	contentLength := int64(0)
+	switch {
+	case sp.BodyStream != nil:
+		body, contentLength = sp.BodyStream, -1
+	case sp.Body != "":
+		body, contentLength = io.NopCloser(strings.NewReader(sp.Body)), int64(len(sp.Body))
+	}
+	status := sp.Status
+	if status == "" {
+		status = strconv.Itoa(sp.StatusCode) + " " + http.StatusText(sp.StatusCode)
+	}
+	return &http.Response{
+		Status:        status,
+		StatusCode:    sp.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: contentLength,
+		Request:       req,
+	}
+}
+
+// 捕获请求body. 可以通过 GetBody 获取副本时读取副本, 长度已知且不超过捕获大小时读取后替换body,
+// 否则在发送时边读边捕获, 返回的 captureReader 在请求完成后可以获取捕获的数据
+func (t Transport) captureRequestBody(r *roundTripReq) (*captureReader, error) {
+	req := r.req
+	limit := t.maxCaptureBytes()
+	if req.Body == nil || req.Body == http.NoBody || limit == 0 {
+		r.BodyTruncated = req.Body != nil && req.Body != http.NoBody
+		return nil, nil
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err == nil {
+			bs, _ := io.ReadAll(io.LimitReader(body, limit+1))
+			_ = body.Close()
+			r.BodyTruncated = int64(len(bs)) > limit
+			r.Body = string(bs[:min(int64(len(bs)), limit)])
+			return nil, nil
+		}
+	}
+
+	if req.ContentLength > 0 && req.ContentLength <= limit {
+		bs, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(bs))
+		r.Body = string(bs)
+		return nil, nil
+	}
+
+	capture := &captureReader{ReadCloser: req.Body, limit: limit}
+	req.Body = capture
+	return capture, nil
+}
+
+// 捕获响应body, 最多读取捕获大小的数据, 读取的部分会放回body前面. 流式响应不读取
+func (t Transport) captureResponseBody(sp *roundTripResponse) error {
+	rsp := sp.rsp
+	limit := t.maxCaptureBytes()
+	if rsp.Body == nil || rsp.Body == http.NoBody || rsp.ContentLength == 0 {
+		return nil
+	}
+	if limit == 0 || isStreamContentType(rsp.Header.Get("Content-Type")) {
+		sp.BodyTruncated = true
+		return nil
+	}
+
+	// 多读一个字节用于判断是否超过捕获大小
+	body, err := io.ReadAll(io.LimitReader(rsp.Body, limit+1))
+	if err != nil {
+		_ = rsp.Body.Close()
+		return err
+	}
+	// 读取完毕后再关闭, 以便 Transport 的超时在此时才取消
+	rsp.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(body), rsp.Body), closer: rsp.Body}
+	sp.BodyTruncated = int64(len(body)) > limit
+	sp.Body = string(body[:min(int64(len(body)), limit)])
+	return nil
+}
+
+func isStreamContentType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/event-stream", "application/x-ndjson", "application/stream+json":
+		return true
+	}
+	return false
+}
+
+type multiReadCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (m *multiReadCloser) Close() error {
+	return m.closer.Close()
+}
+
+// 读取时捕获最多 limit 字节的数据
+type captureReader struct {
+	io.ReadCloser
+	limit int64
+
+	mx        sync.Mutex
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (c *captureReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 {
+		c.mx.Lock()
+		remain := c.limit - int64(c.buf.Len())
+		if int64(n) > remain {
+			c.buf.Write(p[:remain])
+			c.truncated = true
+		} else {
+			c.buf.Write(p[:n])
+		}
+		c.mx.Unlock()
+	}
+	return n, err
+}
+
+// 获取已捕获的数据
+func (c *captureReader) captured() (string, bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.buf.String(), c.truncated
+}
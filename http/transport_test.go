@@ -0,0 +1,48 @@
+package http
+
+import (
+	"io"
+	stdhttp "net/http"
+	"strings"
+	"testing"
+)
+
+func TestCaptureResponseBody(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		contentLength int64
+		contentType   string
+		maxCapture    int64
+		wantBody      string
+		wantTruncated bool
+	}{
+		{name: "known length", body: "hello", contentLength: 5, maxCapture: 8, wantBody: "hello"},
+		{name: "chunked", body: "hello", contentLength: -1, maxCapture: 8, wantBody: "hello"},
+		{name: "exactly limit", body: "12345678", contentLength: -1, maxCapture: 8, wantBody: "12345678"},
+		{name: "chunked larger than limit", body: "0123456789", contentLength: -1, maxCapture: 8, wantBody: "01234567", wantTruncated: true},
+		{name: "known length larger than limit", body: "0123456789", contentLength: 10, maxCapture: 8, wantBody: "01234567", wantTruncated: true},
+		{name: "stream", body: "data: a\n\n", contentLength: -1, contentType: "text/event-stream", maxCapture: 8, wantTruncated: true},
+		{name: "capture disabled", body: "hello", contentLength: -1, maxCapture: -1, wantTruncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsp := &stdhttp.Response{
+				Header:        stdhttp.Header{"Content-Type": {tt.contentType}},
+				Body:          io.NopCloser(strings.NewReader(tt.body)),
+				ContentLength: tt.contentLength,
+			}
+			sp := &roundTripResponse{rsp: rsp}
+			if err := (Transport{MaxCaptureBytes: tt.maxCapture}).captureResponseBody(sp); err != nil {
+				t.Fatal(err)
+			}
+			if sp.Body != tt.wantBody || sp.BodyTruncated != tt.wantTruncated {
+				t.Errorf("captured %q, truncated %v, want %q, %v", sp.Body, sp.BodyTruncated, tt.wantBody, tt.wantTruncated)
+			}
+			// 捕获后实际响应的body不变
+			if body, _ := io.ReadAll(rsp.Body); string(body) != tt.body {
+				t.Errorf("response body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
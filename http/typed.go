@@ -0,0 +1,90 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 空的请求或响应数据, 作为 In 时不发送请求body
+type Empty = struct{}
+
+// 替换路径中的 {key} 为 value, value 会进行路径编码. 需要在设置路径后使用, 如
+//
+//	c.Get(ctx, "/users/{id}", http.WithPathParam("id", "1"))
+func WithPathParam(key, value string) Option {
+	return func(r *Request) {
+		r.Path = strings.ReplaceAll(r.Path, "{"+key+"}", url.PathEscape(value))
+	}
+}
+
+// 按json发送 in 并将响应按json解析为 Out, 响应状态码不是 2xx 时返回 *StatusError, 可以使用 WithExpectStatus 修改期望的状态码.
+// in 为 Empty 时不发送body, 响应body为空时返回 Out 的零值
+func DoJSON[In, Out any](ctx context.Context, c Client, method, path string, in In, opts ...Option) (Out, *Response, error) {
+	var out Out
+	req := NewRequest(method, path, "")
+	req.applyOptions(WithExpectStatus())
+	if _, ok := any(in).(Empty); !ok {
+		req.applyOptions(WithInJson(in))
+	}
+	req.applyOptions(opts...)
+	if req.Header == nil {
+		req.Header = make(Header)
+	}
+	setHeaderIfAbsent(req.Header, "Accept", "application/json")
+
+	sp, err := c.Do(ctx, req)
+	if err != nil {
+		return out, sp, err
+	}
+	if sp.Body == "" {
+		return out, sp, nil
+	}
+	codec, err := getCodec(CodecJson)
+	if err != nil {
+		return out, sp, err
+	}
+	if err = codec.Unmarshal([]byte(sp.Body), &out); err != nil {
+		return out, sp, err
+	}
+	return out, sp, nil
+}
+
+// 发送Get请求并将响应按json解析为 T, 其它行为和 DoJSON 相同
+func GetJSON[T any](ctx context.Context, c Client, path string, opts ...Option) (T, *Response, error) {
+	return DoJSON[Empty, T](ctx, c, http.MethodGet, path, Empty{}, opts...)
+}
+
+// 按json发送Post请求并将响应按json解析为 Out, 其它行为和 DoJSON 相同
+func PostJSON[In, Out any](ctx context.Context, c Client, path string, in In, opts ...Option) (Out, *Response, error) {
+	return DoJSON[In, Out](ctx, c, http.MethodPost, path, in, opts...)
+}
+
+// 按json发送Put请求并将响应按json解析为 Out, 其它行为和 DoJSON 相同
+func PutJSON[In, Out any](ctx context.Context, c Client, path string, in In, opts ...Option) (Out, *Response, error) {
+	return DoJSON[In, Out](ctx, c, http.MethodPut, path, in, opts...)
+}
+
+// 类型化的api定义, 每个下游接口定义一次, 调用时只需要传入请求数据
+//
+//	var getUser = http.NewEndpoint[http.Empty, User](c, "GET", "/users/{id}")
+//	user, _, err := getUser.Call(ctx, http.Empty{}, http.WithPathParam("id", "1"))
+type Endpoint[In, Out any] struct {
+	Client Client
+	Method string
+	Path   string
+	Opts   []Option // 每次调用都会使用的选项, 在调用时传入的选项之前生效
+}
+
+func NewEndpoint[In, Out any](c Client, method, path string, opts ...Option) *Endpoint[In, Out] {
+	return &Endpoint[In, Out]{Client: c, Method: method, Path: path, Opts: opts}
+}
+
+// 调用api, 行为和 DoJSON 相同
+func (e *Endpoint[In, Out]) Call(ctx context.Context, in In, opts ...Option) (Out, *Response, error) {
+	all := make([]Option, 0, len(e.Opts)+len(opts))
+	all = append(all, e.Opts...)
+	all = append(all, opts...)
+	return DoJSON[In, Out](ctx, e.Client, e.Method, e.Path, in, all...)
+}
@@ -0,0 +1,272 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gorilla/websocket"
+	"github.com/zly-app/zapp/pkg/utils"
+)
+
+// websocket消息类型
+const (
+	TextMessage   = websocket.TextMessage
+	BinaryMessage = websocket.BinaryMessage
+)
+
+// 握手失败时响应body最多读取的字节数
+const wsHandshakeBodyLimit = 4096
+
+// 使用自定义 RoundTripper 的客户端不支持websocket
+var ErrWebSocketUnsupported = errors.New("http: websocket is not supported by clients with a custom RoundTripper")
+
+// websocket配置
+type wsConf struct {
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	subprotocols []string
+}
+
+// 设置websocket的ping间隔, 超过 interval+timeout 没有收到pong或其它消息时读取会返回超时错误. 需要持续读取消息才能处理pong
+func WithWSPing(interval, timeout time.Duration) Option {
+	return func(r *Request) {
+		r.ws.pingInterval = interval
+		r.ws.pongTimeout = timeout
+	}
+}
+
+// 设置websocket握手时请求的子协议
+func WithWSSubprotocols(protocols ...string) Option {
+	return func(r *Request) {
+		r.ws.subprotocols = protocols
+	}
+}
+
+// websocket连接, 写入方法可以并发调用, 读取方法只能在一个协程中调用
+type WSConn struct {
+	conn *websocket.Conn
+
+	wmx       sync.Mutex
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newWSConn(conn *websocket.Conn, conf wsConf) *WSConn {
+	c := &WSConn{conn: conn, done: make(chan struct{})}
+	if conf.pingInterval > 0 {
+		wait := conf.pingInterval + conf.pongTimeout
+		_ = conn.SetReadDeadline(time.Now().Add(wait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wait))
+		})
+		go c.keepalive(conf.pingInterval, conf.pongTimeout)
+	}
+	return c
+}
+
+func (c *WSConn) keepalive(interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			deadline := time.Now().Add(max(timeout, time.Second))
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// 获取底层连接
+func (c *WSConn) Conn() *websocket.Conn {
+	return c.conn
+}
+
+// 读取一条消息, 返回消息类型 TextMessage 或 BinaryMessage
+func (c *WSConn) ReadMessage() (messageType int, data []byte, err error) {
+	return c.conn.ReadMessage()
+}
+
+// 写入一条消息
+func (c *WSConn) WriteMessage(messageType int, data []byte) error {
+	c.wmx.Lock()
+	defer c.wmx.Unlock()
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// 读取一条消息并按json解析到 v
+func (c *WSConn) ReadJSON(v interface{}) error {
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return sonic.Unmarshal(data, v)
+}
+
+// 将 v 序列化为json后作为文本消息写入
+func (c *WSConn) WriteJSON(v interface{}) error {
+	data, err := sonic.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, data)
+}
+
+// 发送关闭消息后关闭连接
+func (c *WSConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.wmx.Lock()
+		_ = c.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		c.wmx.Unlock()
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// 建立websocket连接, 使用客户端的代理, tls, dns和默认header配置, url 可以使用 http(s) 或 ws(s).
+// 握手请求会附加trace和主调信息并经过 zapp filter, 返回的 Response 为握手响应, 握手失败时也会返回
+func (c *cli) Dial(ctx context.Context, url string, opts ...Option) (*WSConn, *Response, error) {
+	conn, err := c.getConn()
+	if err != nil {
+		return nil, nil, err
+	}
+	if conn.rt != nil {
+		return nil, nil, ErrWebSocketUnsupported
+	}
+
+	r := NewRequest(http.MethodGet, url, "")
+	r.applyOptions(opts...)
+	conn.applyDefault(r)
+	if r.relPath != "" {
+		r.Path = joinBaseUrl(conn.lb.pick(r, nil).baseUrl, r.relPath)
+	}
+	if conn.auth != nil && r.Header.Get("Authorization") == "" {
+		if err = conn.auth.authorize(ctx, r); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// 附加trace
+	utils.Trace.SaveToHeaders(ctx, r.Header)
+
+	if isWithoutZAppFilter(ctx) {
+		return c.dialWS(ctx, conn, r)
+	}
+
+	// filter看到的是脱敏后的副本, filter的修改会同步到实际请求和握手响应, 包含脱敏值的部分除外
+	view := conn.redact.request(r)
+	base := snapshotRequest(view)
+	var ws *WSConn
+	var handshake, handshakeBase *Response
+	rsp, err := handleClientFilter(ctx, c.Name, "websocket", 1, r.Header, view, func(ctx context.Context, req interface{}) (rsp interface{}, err error) {
+		mergeRequest(r, base, req.(*Request))
+		ws, handshake, err = c.dialWS(ctx, conn, r)
+		if handshake == nil {
+			return nil, err
+		}
+		handshakeView := conn.redact.response(handshake)
+		handshakeBase = snapshotResponse(handshakeView)
+		return handshakeView, err
+	})
+	v, _ := rsp.(*Response)
+	if handshake != nil && v != nil {
+		mergeResponse(handshake, handshakeBase, v)
+	}
+	if err != nil {
+		if ws != nil {
+			_ = ws.Close()
+		}
+		return nil, handshake, err
+	}
+	if handshake == nil {
+		return ws, v, nil
+	}
+	return ws, handshake, nil
+}
+
+func (c *cli) dialWS(ctx context.Context, conn *clientConn, r *Request) (*WSConn, *Response, error) {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	tlsConf := conn.tls.merge(r.tls)
+	if r.InsecureSkipVerify {
+		tlsConf.InsecureSkipVerify = true
+	}
+	tlsClientConf, err := tlsConf.build()
+	if err != nil {
+		return nil, nil, err
+	}
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(conn.conf.DialTimeout) * time.Millisecond,
+		KeepAlive: time.Duration(conn.conf.KeepAlive) * time.Millisecond,
+	}
+	d := &websocket.Dialer{
+		Proxy:            proxyResolve,
+		NetDialContext:   conn.dns.dialContext(dialer),
+		TLSClientConfig:  tlsClientConf,
+		HandshakeTimeout: time.Duration(conn.conf.TLSHandshakeTimeout) * time.Millisecond,
+		Subprotocols:     r.ws.subprotocols,
+		Jar:              r.jar,
+	}
+
+	header := r.Header.Clone()
+	// 这些header由websocket库设置
+	for _, k := range []string{"Upgrade", "Connection", "Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Extensions", "Sec-Websocket-Protocol"} {
+		header.Del(k)
+	}
+
+	// 和 Do 一样将请求参数附加到地址中
+	u, err := url.Parse(r.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	addParams(u, r.Params)
+
+	wsConn, httpRsp, err := d.DialContext(saveProxy2Ctx(ctx, r.Proxy), toWSUrl(u.String()), header)
+	var sp *Response
+	if httpRsp != nil {
+		sp = &Response{
+			Status:        httpRsp.Status,
+			StatusCode:    httpRsp.StatusCode,
+			ContentLength: httpRsp.ContentLength,
+			Header:        httpRsp.Header,
+		}
+		if err != nil && httpRsp.Body != nil {
+			body, _ := io.ReadAll(io.LimitReader(httpRsp.Body, wsHandshakeBodyLimit))
+			_ = httpRsp.Body.Close()
+			sp.Body = string(body)
+		}
+	}
+	if err != nil {
+		return nil, sp, err
+	}
+	return newWSConn(wsConn, r.ws), sp, nil
+}
+
+// 将 http(s) 地址转为 ws(s) 地址
+func toWSUrl(u string) string {
+	switch {
+	case strings.HasPrefix(u, "http://"):
+		return "ws://" + strings.TrimPrefix(u, "http://")
+	case strings.HasPrefix(u, "https://"):
+		return "wss://" + strings.TrimPrefix(u, "https://")
+	}
+	return u
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"context"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// 握手请求带有地址中的参数和 WithInParams 设置的参数
+func TestDialParams(t *testing.T) {
+	queries := make(chan url.Values, 1)
+	srv := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		queries <- r.URL.Query()
+		ws, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		_ = ws.Close()
+	}))
+	defer srv.Close()
+
+	conf := newConfig()
+	if err := conf.Check(); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClientWithRoundTripper("test-ws-params", conf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+
+	ws, _, err := c.Dial(WithoutZAppFilter(context.Background()), srv.URL+"/ws?a=1", WithInParams(url.Values{"token": {"x y"}, "a": {"2"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = ws.Close()
+
+	want := url.Values{"a": {"1", "2"}, "token": {"x y"}}
+	if got := <-queries; got.Encode() != want.Encode() {
+		t.Errorf("query = %v, want %v", got, want)
+	}
+}